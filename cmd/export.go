@@ -6,14 +6,27 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/markdown"
 	"gtasks2md/internal/sync"
 )
 
 var exportListName string
+var exportDryRun bool
+var exportDelete string
+var exportConflict string
+var exportNesting string
+var exportCompletionDates bool
+var exportSections bool
+var exportHeadingTasks bool
+var exportNoFrontMatter bool
+var exportMaxDeletePercent int
+var exportMaxDelete int
+var exportForce bool
 
 var exportCmd = &cobra.Command{
 	Use:   "export [output_path]",
-	Short: "Exports task lists from Google Tasks to local Markdown files.",
+	Short: "Exports task lists from Google Tasks to local files, in Markdown by default, merging in local edits.",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		outputPath := "."
@@ -21,7 +34,43 @@ var exportCmd = &cobra.Command{
 			outputPath = args[0]
 		}
 		
-		err := sync.ExportTasks(outputPath, exportListName, credentialsPath)
+		deletePolicy, err := sync.ParseDeletePolicy(exportDelete)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		strategy, err := sync.ParseConflictStrategy(exportConflict)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		nesting, err := sync.ParseNestingPolicy(exportNesting)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fileFormat, err := format.Lookup(formatName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := sync.SyncOptions{
+			DryRun:           exportDryRun,
+			Delete:           deletePolicy,
+			Conflict:         strategy,
+			MaxDeletePercent: exportMaxDeletePercent,
+			MaxDeleteCount:   exportMaxDelete,
+			Force:            exportForce,
+			Nesting:          nesting,
+			Markdown:         markdown.SerializerOptions{CompletionDates: exportCompletionDates, Sections: exportSections, HeadingTasks: exportHeadingTasks, NoFrontMatter: exportNoFrontMatter},
+			Format:           fileFormat,
+		}
+
+		err = sync.ExportTasks(outputPath, exportListName, credentialsPath, opts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -32,4 +81,15 @@ var exportCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().StringVarP(&exportListName, "list-name", "l", "", "Specify a single Google Task list name to export (required if output_path is a single file).")
+	exportCmd.Flags().BoolVar(&exportDryRun, "dry-run", false, "Print the changes that merging local edits would push to Google Tasks, without modifying Google Tasks or the files.")
+	exportCmd.Flags().StringVar(&exportDelete, "delete", string(sync.DeleteAlways), "Which remote tasks deleted from a synced file to delete: never, completed or always.")
+	exportCmd.Flags().StringVar(&exportConflict, "conflict", string(sync.ConflictLocalWins), "Conflict strategy when a task changed both in a synced file and on Google Tasks: local-wins, remote-wins, newest-wins or mark.")
+	exportCmd.Flags().StringVar(&exportNesting, "nesting", string(sync.NestingFlatten), "What to do with tasks of a synced file nested deeper than Google Tasks allows: flatten them into their nearest allowed parent, or reject the file.")
+	exportCmd.Flags().BoolVar(&exportCompletionDates, "completion-dates", false, "Append the completion date of completed tasks, as in \"✅ 2026-10-14\".")
+	exportCmd.Flags().BoolVar(&exportSections, "sections", false, "Write task lists as \"## \" sections of a single file; without --list-name, every list is exported to it.")
+	exportCmd.Flags().BoolVar(&exportHeadingTasks, "heading-tasks", false, "Write tasks with subtasks as \"##\" headings, with their subtasks under them.")
+	exportCmd.Flags().BoolVar(&exportNoFrontMatter, "no-front-matter", false, "Write files without the gtasks_list_id and synced_at front matter keys; other front matter is kept as written.")
+	exportCmd.Flags().IntVar(&exportMaxDeletePercent, "max-delete-percent", sync.DefaultMaxDeletePercent, "Abort a list's export if merging local edits would delete more than this percentage of its remote tasks (0 disables).")
+	exportCmd.Flags().IntVar(&exportMaxDelete, "max-delete", sync.DefaultMaxDeleteCount, "Abort a list's export if merging local edits would delete more than this many remote tasks (0 disables).")
+	exportCmd.Flags().BoolVar(&exportForce, "force", false, "Apply deletions even beyond the --max-delete-percent and --max-delete limits.")
 }
@@ -6,10 +6,22 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/markdown"
 	"gtasks2md/internal/sync"
 )
 
 var importListName string
+var importDryRun bool
+var importDelete string
+var importMaxDeletePercent int
+var importMaxDelete int
+var importForce bool
+var importNesting string
+var importCompletionDates bool
+var importSections bool
+var importHeadingTasks bool
+var importNoFrontMatter bool
 
 var importCmd = &cobra.Command{
 	Use:   "import <input_path>",
@@ -17,8 +29,37 @@ var importCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		inputPath := args[0]
-		
-		err := sync.ImportTasks(inputPath, importListName, credentialsPath)
+
+		deletePolicy, err := sync.ParseDeletePolicy(importDelete)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		nesting, err := sync.ParseNestingPolicy(importNesting)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fileFormat, err := format.Lookup(formatName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := sync.SyncOptions{
+			DryRun:           importDryRun,
+			Delete:           deletePolicy,
+			MaxDeletePercent: importMaxDeletePercent,
+			MaxDeleteCount:   importMaxDelete,
+			Force:            importForce,
+			Nesting:          nesting,
+			Markdown:         markdown.SerializerOptions{CompletionDates: importCompletionDates, Sections: importSections, HeadingTasks: importHeadingTasks, NoFrontMatter: importNoFrontMatter},
+			Format:           fileFormat,
+		}
+
+		err = sync.ImportTasks(inputPath, importListName, credentialsPath, opts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -29,4 +70,14 @@ var importCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(importCmd)
 	importCmd.Flags().StringVarP(&importListName, "list-name", "l", "", "Target Google Tasks list name (optional override).")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Print the planned changes without modifying Google Tasks.")
+	importCmd.Flags().StringVar(&importDelete, "delete", string(sync.DeleteAlways), "Which remote tasks missing from the Markdown file to delete: never, completed or always.")
+	importCmd.Flags().IntVar(&importMaxDeletePercent, "max-delete-percent", sync.DefaultMaxDeletePercent, "Abort a list's sync if it would delete more than this percentage of its remote tasks (0 disables).")
+	importCmd.Flags().IntVar(&importMaxDelete, "max-delete", sync.DefaultMaxDeleteCount, "Abort a list's sync if it would delete more than this many remote tasks (0 disables).")
+	importCmd.Flags().BoolVar(&importForce, "force", false, "Apply deletions even beyond the --max-delete-percent and --max-delete limits.")
+	importCmd.Flags().StringVar(&importNesting, "nesting", string(sync.NestingFlatten), "What to do with tasks nested deeper than Google Tasks allows: flatten them into their nearest allowed parent, or reject the file.")
+	importCmd.Flags().BoolVar(&importCompletionDates, "completion-dates", false, "When rewriting Markdown files, append the completion date of completed tasks, as in \"✅ 2026-10-14\".")
+	importCmd.Flags().BoolVar(&importSections, "sections", false, "Read each \"## \" section of a file as a task list of its own.")
+	importCmd.Flags().BoolVar(&importHeadingTasks, "heading-tasks", false, "Read \"##\" to \"######\" headings as parent tasks of the checkboxes under them.")
+	importCmd.Flags().BoolVar(&importNoFrontMatter, "no-front-matter", false, "When rewriting Markdown files, write them without the gtasks_list_id and synced_at front matter keys; other front matter is kept as written.")
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gtasks2md/internal/sync"
+)
+
+var restoreListName string
+var restoreTitle string
+var restoreShow bool
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [path]",
+	Short: "Restores tasks deleted from Google Tasks by a previous import.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "."
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		err := sync.RestoreTasks(path, restoreListName, restoreTitle, credentialsPath, restoreShow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVarP(&restoreListName, "list-name", "l", "", "Only restore tasks deleted from the list with this name.")
+	restoreCmd.Flags().StringVarP(&restoreTitle, "title", "t", "", "Only restore tasks with this title.")
+	restoreCmd.Flags().BoolVar(&restoreShow, "list", false, "List the tasks in the trash instead of restoring them.")
+}
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"gtasks2md/internal/format"
 )
 
 var credentialsPath string
+var formatName string
 
 var rootCmd = &cobra.Command{
 	Use:   "gtasks2md",
@@ -24,4 +29,5 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&credentialsPath, "credentials", "c", "", "Path to the OAuth 2.0 credentials.json file.")
+	rootCmd.PersistentFlags().StringVarP(&formatName, "format", "f", format.DefaultName, fmt.Sprintf("Format of the local task files: %s.", strings.Join(format.Names(), ", ")))
 }
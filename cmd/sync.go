@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/markdown"
+	"gtasks2md/internal/sync"
+)
+
+var syncListName string
+var syncConflict string
+var syncMaxDeletePercent int
+var syncMaxDelete int
+var syncForce bool
+var syncNesting string
+var syncCompletionDates bool
+var syncSections bool
+var syncHeadingTasks bool
+var syncNoFrontMatter bool
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <path>",
+	Short: "Synchronizes Google Tasks and local Markdown files in both directions.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+
+		strategy, err := sync.ParseConflictStrategy(syncConflict)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		nesting, err := sync.ParseNestingPolicy(syncNesting)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fileFormat, err := format.Lookup(formatName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := sync.SyncOptions{
+			Conflict:         strategy,
+			MaxDeletePercent: syncMaxDeletePercent,
+			MaxDeleteCount:   syncMaxDelete,
+			Force:            syncForce,
+			Nesting:          nesting,
+			Markdown:         markdown.SerializerOptions{CompletionDates: syncCompletionDates, Sections: syncSections, HeadingTasks: syncHeadingTasks, NoFrontMatter: syncNoFrontMatter},
+			Format:           fileFormat,
+		}
+
+		err = sync.SyncTasks(path, syncListName, credentialsPath, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVarP(&syncListName, "list-name", "l", "", "Sync only the Google Task list with this name.")
+	syncCmd.Flags().StringVar(&syncConflict, "conflict", string(sync.ConflictLocalWins), "Conflict strategy when a task changed on both sides: local-wins, remote-wins, newest-wins or mark.")
+	syncCmd.Flags().IntVar(&syncMaxDeletePercent, "max-delete-percent", sync.DefaultMaxDeletePercent, "Abort a list's sync if it would delete more than this percentage of its remote tasks (0 disables).")
+	syncCmd.Flags().IntVar(&syncMaxDelete, "max-delete", sync.DefaultMaxDeleteCount, "Abort a list's sync if it would delete more than this many remote tasks (0 disables).")
+	syncCmd.Flags().BoolVar(&syncForce, "force", false, "Apply deletions even beyond the --max-delete-percent and --max-delete limits.")
+	syncCmd.Flags().StringVar(&syncNesting, "nesting", string(sync.NestingFlatten), "What to do with tasks nested deeper than Google Tasks allows: flatten them into their nearest allowed parent, or reject the file.")
+	syncCmd.Flags().BoolVar(&syncCompletionDates, "completion-dates", false, "When rewriting Markdown files, append the completion date of completed tasks, as in \"✅ 2026-10-14\".")
+	syncCmd.Flags().BoolVar(&syncSections, "sections", false, "Read and write each \"## \" section of a file as a task list of its own.")
+	syncCmd.Flags().BoolVar(&syncHeadingTasks, "heading-tasks", false, "Read and write \"##\" to \"######\" headings as parent tasks of the checkboxes under them.")
+	syncCmd.Flags().BoolVar(&syncNoFrontMatter, "no-front-matter", false, "When rewriting Markdown files, write them without the gtasks_list_id and synced_at front matter keys; other front matter is kept as written.")
+}
@@ -5,6 +5,7 @@ go 1.25.0
 require (
 	golang.org/x/oauth2 v0.35.0
 	google.golang.org/api v0.268.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
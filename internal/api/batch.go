@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gtasks2md/internal/models"
+)
+
+// MaxBatchSize is the largest number of calls sent in a single batch request.
+const MaxBatchSize = 100
+
+// batchTask is the JSON representation of a task in batch requests and responses.
+type batchTask struct {
+	ID     string  `json:"id,omitempty"`
+	Title  string  `json:"title"`
+	Status string  `json:"status,omitempty"`
+	Notes  *string `json:"notes"`
+	Due    *string `json:"due"`
+	Parent string  `json:"parent,omitempty"`
+}
+
+type batchCall struct {
+	action string
+	method string
+	path   string
+	body   *batchTask
+	task   *models.Task
+}
+
+// BatchResult is the outcome of a single call of a batch.
+type BatchResult struct {
+	// Task is the task the call was queued for.
+	Task *models.Task
+	// Err is set when the call failed.
+	Err error
+}
+
+// Batch groups task mutations into multipart batch requests, saving a round trip per call.
+// Calls are sent in the order they were queued.
+type Batch struct {
+	client *GoogleTasksClient
+	calls  []*batchCall
+}
+
+// NewBatch starts an empty batch.
+func (c *GoogleTasksClient) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// CreateTask queues the creation of a task, like GoogleTasksClient.CreateTask.
+// The ID of the created task is assigned to task when the batch is run.
+func (b *Batch) CreateTask(tasklistID string, task *models.Task, parentID string, previousID string) {
+	query := url.Values{}
+	if parentID != "" {
+		query.Set("parent", parentID)
+	}
+	if previousID != "" {
+		query.Set("previous", previousID)
+	}
+	b.queue("create", "POST", tasksPath(tasklistID, "")+encodeQuery(query), &batchTask{
+		Title:  task.Title,
+		Status: task.Status,
+		Notes:  task.Notes,
+		Due:    dueValue(task),
+	}, task)
+}
+
+// UpdateTask queues a patch of the title, status, notes and due date of a task, like GoogleTasksClient.UpdateTask.
+func (b *Batch) UpdateTask(tasklistID string, task *models.Task) {
+	b.queue("update", "PATCH", tasksPath(tasklistID, *task.ID), &batchTask{
+		ID:     *task.ID,
+		Title:  task.Title,
+		Status: task.Status,
+		Notes:  task.Notes,
+		Due:    dueValue(task),
+	}, task)
+}
+
+// MoveTask queues a move of a task, like GoogleTasksClient.MoveTask.
+func (b *Batch) MoveTask(tasklistID string, task *models.Task, parentID string, previousID string) {
+	query := url.Values{}
+	if parentID != "" {
+		query.Set("parent", parentID)
+	}
+	if previousID != "" {
+		query.Set("previous", previousID)
+	}
+	b.queue("move", "POST", tasksPath(tasklistID, *task.ID)+"/move"+encodeQuery(query), nil, task)
+}
+
+// DeleteTask queues the deletion of a task, like GoogleTasksClient.DeleteTask.
+func (b *Batch) DeleteTask(tasklistID string, task *models.Task) {
+	b.queue("delete", "DELETE", tasksPath(tasklistID, *task.ID), nil, task)
+}
+
+func (b *Batch) queue(action string, method string, path string, body *batchTask, task *models.Task) {
+	b.calls = append(b.calls, &batchCall{action: action, method: method, path: path, body: body, task: task})
+}
+
+// Do sends the queued calls, at most MaxBatchSize per request, and returns one result
+// per call in the order they were queued. A request that fails as a whole fails all its calls.
+func (b *Batch) Do() []*BatchResult {
+	results := make([]*BatchResult, len(b.calls))
+	for start := 0; start < len(b.calls); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(b.calls))
+		chunk := b.calls[start:end]
+
+		errs, err := b.client.sendBatch(chunk)
+		for i, call := range chunk {
+			result := &BatchResult{Task: call.task, Err: err}
+			if err == nil {
+				result.Err = errs[i]
+			}
+			results[start+i] = result
+		}
+	}
+	b.calls = nil
+	return results
+}
+
+// sendBatch sends calls in a single batch request and returns the error of each call.
+func (c *GoogleTasksClient) sendBatch(calls []*batchCall) ([]error, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for i, call := range calls {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-ID":   {fmt.Sprintf("<item%d>", i)},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode batch request: %v", err)
+		}
+
+		fmt.Fprintf(part, "%s %s HTTP/1.1\r\n", call.method, call.path)
+		if call.body != nil {
+			data, err := json.Marshal(call.body)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode task '%s': %v", call.task.Title, err)
+			}
+			fmt.Fprintf(part, "Content-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(data), data)
+		} else {
+			fmt.Fprint(part, "\r\n")
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("unable to encode batch request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", c.batchURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create batch request: %v", err)
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("batch request failed: %s", errorMessage(resp))
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, fmt.Errorf("unexpected batch response type '%s'", resp.Header.Get("Content-Type"))
+	}
+
+	errs := make([]error, len(calls))
+	answered := make([]bool, len(calls))
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read batch response: %v", err)
+		}
+
+		i, ok := responseIndex(part.Header.Get("Content-ID"))
+		if !ok || i >= len(calls) {
+			continue
+		}
+		answered[i] = true
+		errs[i] = readBatchResponse(part, calls[i])
+	}
+
+	for i, call := range calls {
+		if !answered[i] {
+			errs[i] = fmt.Errorf("unable to %s task: no response in batch", call.action)
+		}
+	}
+	return errs, nil
+}
+
+// readBatchResponse reads the HTTP response of a single call, assigning the ID of created tasks.
+func readBatchResponse(part io.Reader, call *batchCall) error {
+	resp, err := http.ReadResponse(bufio.NewReader(part), nil)
+	if err != nil {
+		return fmt.Errorf("unable to %s task: invalid batch response: %v", call.action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unable to %s task: %s", call.action, errorMessage(resp))
+	}
+
+	if call.action == "create" {
+		var result batchTask
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("unable to create task: invalid response: %v", err)
+		}
+		id := result.ID
+		call.task.ID = &id
+		if result.Parent != "" {
+			p := result.Parent
+			call.task.Parent = &p
+		}
+	}
+	return nil
+}
+
+// responseIndex extracts the call index from a response Content-ID such as "<response-item3>".
+func responseIndex(contentID string) (int, bool) {
+	id := strings.Trim(contentID, "<>")
+	id = strings.TrimPrefix(id, "response-")
+	if !strings.HasPrefix(id, "item") {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(id, "item"))
+	return i, err == nil && i >= 0
+}
+
+// errorMessage extracts the message of a Google API error response.
+func errorMessage(resp *http.Response) string {
+	data, _ := io.ReadAll(resp.Body)
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Sprintf("%s (%d)", apiErr.Error.Message, resp.StatusCode)
+	}
+	return resp.Status
+}
+
+// dueValue returns the due date of a task as sent in batch requests, nil to clear it.
+func dueValue(task *models.Task) *string {
+	if task.Due == nil {
+		return nil
+	}
+	due := formatDue(task.Due)
+	return &due
+}
+
+func tasksPath(tasklistID string, taskID string) string {
+	path := "/tasks/v1/lists/" + url.PathEscape(tasklistID) + "/tasks"
+	if taskID != "" {
+		path += "/" + url.PathEscape(taskID)
+	}
+	return path
+}
+
+func encodeQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
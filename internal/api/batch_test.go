@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gtasks2md/internal/models"
+)
+
+func TestBatchDo(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+
+		out := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Fatalf("Invalid batch part: %v", err)
+			}
+			body, _ := io.ReadAll(req.Body)
+			requests = append(requests, strings.TrimSpace(req.Method+" "+req.URL.String()+" "+string(body)))
+
+			id := strings.Trim(part.Header.Get("Content-ID"), "<>")
+			resp, _ := out.CreatePart(map[string][]string{
+				"Content-Type": {"application/http"},
+				"Content-ID":   {"<response-" + id + ">"},
+			})
+			switch req.Method {
+			case "POST":
+				fmt.Fprintf(resp, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"id\": \"new-%s\"}", id)
+			case "DELETE":
+				fmt.Fprint(resp, "HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\n\r\n{\"error\": {\"code\": 404, \"message\": \"Task not found\"}}")
+			}
+		}
+		out.Close()
+	}))
+	defer server.Close()
+
+	client := &GoogleTasksClient{httpClient: server.Client(), batchURL: server.URL}
+	notes := "Milk"
+	due, _ := models.ParseDate("2026-10-20")
+	created := &models.Task{Title: "Buy groceries", Status: "needsAction", Notes: &notes, Due: due}
+	deleted := &models.Task{ID: strPtr("old"), Title: "Old idea"}
+
+	batch := client.NewBatch()
+	batch.CreateTask("list", created, "parent", "")
+	batch.DeleteTask("list", deleted)
+	results := batch.Do()
+
+	expectedRequests := []string{
+		`POST /tasks/v1/lists/list/tasks?parent=parent {"title":"Buy groceries","status":"needsAction","notes":"Milk","due":"2026-10-20T00:00:00.000Z"}`,
+		`DELETE /tasks/v1/lists/list/tasks/old`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(expectedRequests, "\n") {
+		t.Errorf("Expected requests:\n%s\ngot:\n%s", strings.Join(expectedRequests, "\n"), strings.Join(requests, "\n"))
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Err != nil || created.ID == nil || *created.ID != "new-item0" {
+		t.Errorf("Expected the created task to get ID 'new-item0', got %v (error %v)", created.ID, results[0].Err)
+	}
+	if results[1].Task != deleted || results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "Task not found") {
+		t.Errorf("Expected the deletion to fail with 'Task not found', got %v", results[1].Err)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
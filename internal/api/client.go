@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"time"
 
 	"google.golang.org/api/option"
 	"google.golang.org/api/tasks/v1"
@@ -14,7 +15,9 @@ import (
 
 // GoogleTasksClient holds the Google Tasks service client.
 type GoogleTasksClient struct {
-	service *tasks.Service
+	service    *tasks.Service
+	httpClient *http.Client
+	batchURL   string
 }
 
 // NewClient initializes a new GoogleTasksClient.
@@ -23,7 +26,11 @@ func NewClient(ctx context.Context, client *http.Client) (*GoogleTasksClient, er
 	if err != nil {
 		return nil, fmt.Errorf("unable to create tasks service: %v", err)
 	}
-	return &GoogleTasksClient{service: service}, nil
+	return &GoogleTasksClient{
+		service:    service,
+		httpClient: client,
+		batchURL:   service.BasePath + "batch/tasks/v1",
+	}, nil
 }
 
 // GetTasklists fetches all task lists and returns them as a slice of TaskList models.
@@ -122,19 +129,39 @@ func (c *GoogleTasksClient) GetTasks(tasklistID string) ([]*models.Task, error)
 			notes = &n
 		}
 
+		var due *time.Time
+		if d, err := time.Parse(time.RFC3339, rt.Due); err == nil {
+			due = &d
+		}
+
+		var completed *time.Time
+		if rt.Completed != nil {
+			if c, err := time.Parse(time.RFC3339, *rt.Completed); err == nil {
+				completed = &c
+			}
+		}
+
 		var parent *string
 		if rt.Parent != "" {
 			p := rt.Parent
 			parent = &p
 		}
 
+		var updated *time.Time
+		if u, err := time.Parse(time.RFC3339, rt.Updated); err == nil {
+			updated = &u
+		}
+
 		id := rt.Id
 		task := &models.Task{
-			ID:     &id,
-			Title:  rt.Title,
-			Status: status,
-			Notes:  notes,
-			Parent: parent,
+			ID:        &id,
+			Title:     rt.Title,
+			Status:    status,
+			Notes:     notes,
+			Due:       due,
+			Parent:    parent,
+			Completed: completed,
+			Updated:   updated,
 		}
 		taskDict[id] = task
 		positions[id] = rt.Position
@@ -169,8 +196,9 @@ func (c *GoogleTasksClient) GetTasks(tasklistID string) ([]*models.Task, error)
 	return rootTasks, nil
 }
 
-// CreateTask creates a new task in the specified list, optionally as a child of parentID.
-func (c *GoogleTasksClient) CreateTask(tasklistID string, task *models.Task, parentID string) (*models.Task, error) {
+// CreateTask creates a new task in the specified list, optionally as a child of parentID
+// and placed after the sibling previousID. Without previousID the task becomes the first sibling.
+func (c *GoogleTasksClient) CreateTask(tasklistID string, task *models.Task, parentID string, previousID string) (*models.Task, error) {
 	t := &tasks.Task{
 		Title:  task.Title,
 		Status: task.Status,
@@ -178,11 +206,17 @@ func (c *GoogleTasksClient) CreateTask(tasklistID string, task *models.Task, par
 	if task.Notes != nil {
 		t.Notes = *task.Notes
 	}
+	if task.Due != nil {
+		t.Due = formatDue(task.Due)
+	}
 
 	req := c.service.Tasks.Insert(tasklistID, t)
 	if parentID != "" {
 		req.Parent(parentID)
 	}
+	if previousID != "" {
+		req.Previous(previousID)
+	}
 
 	result, err := req.Do()
 	if err != nil {
@@ -214,7 +248,12 @@ func (c *GoogleTasksClient) UpdateTask(tasklistID string, task *models.Task) (*m
 	if task.Notes != nil {
 		t.Notes = *task.Notes
 	} else {
-		t.NullFields = []string{"Notes"}
+		t.NullFields = append(t.NullFields, "Notes")
+	}
+	if task.Due != nil {
+		t.Due = formatDue(task.Due)
+	} else {
+		t.NullFields = append(t.NullFields, "Due")
 	}
 
 	// Use Patch instead of Update to preserve unspecified fields
@@ -229,6 +268,36 @@ func (c *GoogleTasksClient) UpdateTask(tasklistID string, task *models.Task) (*m
 	return task, nil
 }
 
+// MoveTask moves a task under parentID (or to the top level if empty), placing it after
+// the sibling previousID (or first if empty).
+func (c *GoogleTasksClient) MoveTask(tasklistID string, taskID string, parentID string, previousID string) (*models.Task, error) {
+	req := c.service.Tasks.Move(tasklistID, taskID)
+	if parentID != "" {
+		req.Parent(parentID)
+	}
+	if previousID != "" {
+		req.Previous(previousID)
+	}
+
+	result, err := req.Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to move task: %v", err)
+	}
+
+	id := result.Id
+	task := &models.Task{
+		ID:     &id,
+		Title:  result.Title,
+		Status: result.Status,
+	}
+	if result.Parent != "" {
+		p := result.Parent
+		task.Parent = &p
+	}
+
+	return task, nil
+}
+
 // DeleteTask deletes a task.
 func (c *GoogleTasksClient) DeleteTask(tasklistID string, taskID string) error {
 	err := c.service.Tasks.Delete(tasklistID, taskID).Do()
@@ -237,3 +306,8 @@ func (c *GoogleTasksClient) DeleteTask(tasklistID string, taskID string) error {
 	}
 	return nil
 }
+
+// formatDue formats a due date the way Google Tasks stores it, as midnight UTC.
+func formatDue(due *time.Time) string {
+	return due.Format(models.DateLayout) + "T00:00:00.000Z"
+}
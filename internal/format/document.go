@@ -0,0 +1,164 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gtasks2md/internal/models"
+)
+
+// listDocument is a task list as written in structured formats such as JSON and YAML,
+// holding every field of models.TaskList.
+type listDocument struct {
+	ID          *string            `json:"id,omitempty"`
+	Title       string             `json:"title"`
+	SyncedAt    *time.Time         `json:"synced_at,omitempty"`
+	Metadata    map[string]string  `json:"metadata,omitempty"`
+	FrontMatter []string           `json:"front_matter,omitempty"`
+	Tasks       []*taskDocument    `json:"tasks"`
+	Content     []*contentDocument `json:"content,omitempty"`
+}
+
+type taskDocument struct {
+	ID        *string         `json:"id,omitempty"`
+	Title     string          `json:"title"`
+	Status    string          `json:"status"`
+	Notes     *string         `json:"notes,omitempty"`
+	Due       *documentDate   `json:"due,omitempty"`
+	Parent    *string         `json:"parent,omitempty"`
+	Completed *time.Time      `json:"completed,omitempty"`
+	Updated   *time.Time      `json:"updated,omitempty"`
+	Children  []*taskDocument `json:"children,omitempty"`
+}
+
+// documentDate is a due date, written in RFC 3339 and read either that way or as a date only.
+type documentDate time.Time
+
+func (d documentDate) MarshalJSON() ([]byte, error) {
+	return time.Time(d).MarshalJSON()
+}
+
+func (d *documentDate) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid due date %s", data)
+	}
+	if due, err := models.ParseDate(value); err == nil && due != nil {
+		*d = documentDate(*due)
+		return nil
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("invalid due date %q (expected YYYY-MM-DD or RFC 3339)", value)
+	}
+	*d = documentDate(due)
+	return nil
+}
+
+// contentDocument is a block of free-form Markdown content. After is the index of the
+// top-level task the block follows, unset for blocks before the first task, and BeforeTitle
+// marks blocks before the list title.
+type contentDocument struct {
+	Text        string `json:"text"`
+	After       *int   `json:"after,omitempty"`
+	BeforeTitle bool   `json:"before_title,omitempty"`
+}
+
+func newListDocument(tasklist *models.TaskList) *listDocument {
+	doc := &listDocument{
+		ID:          tasklist.ID,
+		Title:       tasklist.Title,
+		SyncedAt:    tasklist.SyncedAt,
+		Metadata:    tasklist.Metadata,
+		FrontMatter: tasklist.FrontMatter,
+		Tasks:       newTaskDocuments(tasklist.Tasks),
+	}
+
+	index := make(map[*models.Task]int)
+	for i, task := range tasklist.Tasks {
+		index[task] = i
+	}
+	for _, block := range tasklist.Content {
+		content := &contentDocument{Text: block.Text, BeforeTitle: block.BeforeTitle}
+		if block.After != nil {
+			// Blocks whose task is gone end up after the last task, as in Markdown files
+			i, ok := index[block.After]
+			if !ok {
+				i = len(tasklist.Tasks) - 1
+			}
+			content.After = &i
+		}
+		doc.Content = append(doc.Content, content)
+	}
+	return doc
+}
+
+func newTaskDocuments(tasks []*models.Task) []*taskDocument {
+	docs := make([]*taskDocument, 0, len(tasks))
+	for _, task := range tasks {
+		docs = append(docs, &taskDocument{
+			ID:        task.ID,
+			Title:     task.Title,
+			Status:    task.Status,
+			Notes:     task.Notes,
+			Due:       (*documentDate)(task.Due),
+			Parent:    task.Parent,
+			Completed: task.Completed,
+			Updated:   task.Updated,
+			Children:  newTaskDocuments(task.Children),
+		})
+	}
+	return docs
+}
+
+// taskList converts a document back to a task list. Tasks without a status are open.
+func (d *listDocument) taskList() *models.TaskList {
+	tasklist := &models.TaskList{
+		ID:          d.ID,
+		Title:       d.Title,
+		SyncedAt:    d.SyncedAt,
+		Metadata:    d.Metadata,
+		FrontMatter: d.FrontMatter,
+		Tasks:       taskModels(d.Tasks),
+	}
+	for _, content := range d.Content {
+		block := &models.ContentBlock{Text: content.Text, BeforeTitle: content.BeforeTitle}
+		if content.After != nil && len(tasklist.Tasks) > 0 {
+			i := *content.After
+			if i < 0 {
+				i = 0
+			} else if i >= len(tasklist.Tasks) {
+				i = len(tasklist.Tasks) - 1
+			}
+			block.After = tasklist.Tasks[i]
+		}
+		tasklist.Content = append(tasklist.Content, block)
+	}
+	return tasklist
+}
+
+func taskModels(docs []*taskDocument) []*models.Task {
+	var tasks []*models.Task
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		status := doc.Status
+		if status == "" {
+			status = "needsAction"
+		}
+		tasks = append(tasks, &models.Task{
+			ID:        doc.ID,
+			Title:     doc.Title,
+			Status:    status,
+			Notes:     doc.Notes,
+			Due:       (*time.Time)(doc.Due),
+			Parent:    doc.Parent,
+			Completed: doc.Completed,
+			Updated:   doc.Updated,
+			Children:  taskModels(doc.Children),
+		})
+	}
+	return tasks
+}
@@ -0,0 +1,112 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gtasks2md/internal/models"
+)
+
+// DefaultName is the name of the format used when none is selected.
+const DefaultName = "markdown"
+
+// Capabilities lists the parts of a task list a format can hold. Whatever a format does not
+// support is dropped when a list is written in it.
+type Capabilities struct {
+	// TaskIDs keeps the Google Tasks IDs of tasks, so that renamed tasks are still matched.
+	TaskIDs bool
+	// Subtasks keeps the nesting of tasks.
+	Subtasks bool
+	// TitleSpacing keeps the whitespace of titles as written, such as line breaks and repeated
+	// spaces, rather than collapsing it to single spaces.
+	TitleSpacing bool
+	// Notes keeps task notes.
+	Notes bool
+	// NoteSpacing keeps the blank lines at the start and end of notes rather than trimming them.
+	NoteSpacing bool
+	// DueDates keeps task due dates.
+	DueDates bool
+	// CompletionDates keeps the completion times of completed tasks.
+	CompletionDates bool
+	// SubtasksNeedIDs is set when subtasks point to their parent by its task ID, so that the
+	// subtasks of tasks without one are moved to the top level.
+	SubtasksNeedIDs bool
+}
+
+// FullCapabilities are those of a format that holds every part of a task list.
+var FullCapabilities = Capabilities{TaskIDs: true, Subtasks: true, TitleSpacing: true, Notes: true, NoteSpacing: true, DueDates: true, CompletionDates: true}
+
+// Format encodes and decodes a task list as the content of a file.
+type Format interface {
+	// Name is the name that selects the format with --format.
+	Name() string
+	// Extension is the file extension of the format, including the leading dot.
+	Extension() string
+	Capabilities() Capabilities
+	Encode(tasklist *models.TaskList) ([]byte, error)
+	Decode(data []byte) (*models.TaskList, error)
+}
+
+var formats = make(map[string]Format)
+
+// Register makes a format available under its name.
+func Register(f Format) {
+	formats[f.Name()] = f
+}
+
+// Lookup returns the registered format with the given name.
+func Lookup(name string) (Format, error) {
+	if f, ok := formats[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown format '%s' (expected %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names lists the names of the registered formats in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unsupported lists the parts of a task list that a format with the given capabilities would drop.
+func Unsupported(tasklist *models.TaskList, caps Capabilities) []string {
+	found := make(map[string]bool)
+	var walk func(tasks []*models.Task)
+	walk = func(tasks []*models.Task) {
+		for _, task := range tasks {
+			if !caps.TaskIDs && task.ID != nil && *task.ID != "" {
+				found["task IDs"] = true
+			}
+			if !caps.Subtasks && len(task.Children) > 0 {
+				found["subtasks"] = true
+			}
+			if caps.Subtasks && caps.SubtasksNeedIDs && len(task.Children) > 0 && (task.ID == nil || *task.ID == "") {
+				found["subtasks of tasks without an ID"] = true
+			}
+			if !caps.Notes && task.Notes != nil && *task.Notes != "" {
+				found["notes"] = true
+			}
+			if !caps.DueDates && task.Due != nil {
+				found["due dates"] = true
+			}
+			if !caps.CompletionDates && task.Completed != nil {
+				found["completion dates"] = true
+			}
+			walk(task.Children)
+		}
+	}
+	walk(tasklist.Tasks)
+
+	var parts []string
+	for _, part := range []string{"task IDs", "subtasks", "subtasks of tasks without an ID", "notes", "due dates", "completion dates"} {
+		if found[part] {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
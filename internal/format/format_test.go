@@ -0,0 +1,484 @@
+package format
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gtasks2md/internal/models"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestLookup(t *testing.T) {
+	f, err := Lookup(DefaultName)
+	if err != nil {
+		t.Fatalf("Expected the default format to be registered, got %v", err)
+	}
+	if f.Extension() != ".md" {
+		t.Errorf("Expected .md for the default format, got %s", f.Extension())
+	}
+
+	if _, err := Lookup("docx"); err == nil || !strings.Contains(err.Error(), "markdown") {
+		t.Errorf("Expected an error listing the known formats, got %v", err)
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	list := &models.TaskList{
+		Title: "Groceries",
+		Tasks: []*models.Task{
+			{ID: strPtr("t1"), Title: "Milk", Status: "needsAction", Due: &due, Notes: strPtr("Oat")},
+			{ID: strPtr("t2"), Title: "Bread", Status: "completed", Children: []*models.Task{
+				{ID: strPtr("t3"), Title: "Rye", Status: "completed"},
+			}},
+		},
+	}
+
+	f, _ := Lookup("markdown")
+	data, err := f.Encode(list)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := f.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if decoded.Title != "Groceries" || len(decoded.Tasks) != 2 {
+		t.Fatalf("Expected the list back, got %+v", decoded)
+	}
+	milk := decoded.Tasks[0]
+	if *milk.ID != "t1" || milk.DueDate() != "2026-10-20" || milk.Notes == nil || *milk.Notes != "Oat" {
+		t.Errorf("Expected Milk with its ID, due date and notes, got %+v", milk)
+	}
+	if len(decoded.Tasks[1].Children) != 1 || decoded.Tasks[1].Children[0].Title != "Rye" {
+		t.Errorf("Expected Bread to keep its subtask, got %+v", decoded.Tasks[1].Children)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	list := &models.TaskList{
+		Tasks: []*models.Task{
+			{Title: "Parent", Children: []*models.Task{
+				{Title: "Child", Due: &due, Notes: strPtr("")},
+			}},
+		},
+	}
+
+	dropped := Unsupported(list, Capabilities{Notes: true})
+	if strings.Join(dropped, ", ") != "subtasks, due dates" {
+		t.Errorf("Expected subtasks and due dates to be dropped, got %v", dropped)
+	}
+	if dropped := Unsupported(list, Capabilities{Subtasks: true, DueDates: true}); len(dropped) != 0 {
+		t.Errorf("Expected nothing to be dropped, got %v", dropped)
+	}
+	dropped = Unsupported(list, Capabilities{Subtasks: true, DueDates: true, SubtasksNeedIDs: true})
+	if strings.Join(dropped, ", ") != "subtasks of tasks without an ID" {
+		t.Errorf("Expected the subtasks of a task without an ID to be dropped, got %v", dropped)
+	}
+}
+
+// fullList returns a list using every field of the model, with titles and notes that
+// need quoting in structured formats.
+func fullList() *models.TaskList {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 10, 14, 9, 30, 15, 0, time.UTC)
+	updated := time.Date(2026, 10, 15, 18, 0, 0, 123000000, time.FixedZone("", 2*60*60))
+	synced := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	child := &models.Task{ID: strPtr("t3"), Title: "- [x] not: a checkbox #tag", Status: "completed", Parent: strPtr("t2"), Completed: &completed}
+	first := &models.Task{ID: strPtr("t1"), Title: "Milk", Status: "needsAction", Due: &due, Notes: strPtr("Oat\n\n  indented\nlast line\n"), Updated: &updated}
+	return &models.TaskList{
+		ID:       strPtr("list1"),
+		Title:    "yes",
+		SyncedAt: &synced,
+		Metadata: map[string]string{"owner": "me", "2": "\"quoted\" 'value'"},
+		Tasks: []*models.Task{
+			first,
+			{ID: strPtr("t2"), Title: "123", Status: "completed", Notes: strPtr(" leading space\ttab\r\n"), Children: []*models.Task{child}},
+			{Title: "", Status: "needsAction", Notes: strPtr("")},
+			{Title: "Ends with newlines", Status: "needsAction", Notes: strPtr("a\n\n")},
+		},
+		Content: []*models.ContentBlock{{Text: "Intro"}, {Text: "| a | b |\n|---|---|", After: first}},
+	}
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	for _, name := range []string{"json", "yaml"} {
+		f, err := Lookup(name)
+		if err != nil {
+			t.Fatalf("Expected %s to be registered, got %v", name, err)
+		}
+		list := fullList()
+		list.FrontMatter = []string{"owner: me", "tags:", "  - a", "", "# comment"}
+		data, err := f.Encode(list)
+		if err != nil {
+			t.Fatalf("%s: Encode failed: %v", name, err)
+		}
+		decoded, err := f.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Decode failed: %v\n%s", name, err, data)
+		}
+
+		if *decoded.ID != "list1" || decoded.Title != "yes" || !decoded.SyncedAt.Equal(*list.SyncedAt) {
+			t.Errorf("%s: Expected the list fields back, got %+v", name, decoded)
+		}
+		if len(decoded.Metadata) != 2 || decoded.Metadata["2"] != list.Metadata["2"] {
+			t.Errorf("%s: Expected the metadata back, got %v", name, decoded.Metadata)
+		}
+		if strings.Join(decoded.FrontMatter, "\n") != strings.Join(list.FrontMatter, "\n") {
+			t.Errorf("%s: Expected the front matter lines back, got %q", name, decoded.FrontMatter)
+		}
+		assertSameTasks(t, name, decoded.Tasks, list.Tasks)
+		if len(decoded.Content) != 2 || decoded.Content[0].After != nil || decoded.Content[1].After != decoded.Tasks[0] || decoded.Content[1].Text != list.Content[1].Text {
+			t.Errorf("%s: Expected the content blocks back, got %+v", name, decoded.Content)
+		}
+
+		again, _ := f.Encode(decoded)
+		if string(again) != string(data) {
+			t.Errorf("%s: Expected encoding to be stable, got\n%s\nthen\n%s", name, data, again)
+		}
+	}
+}
+
+func assertSameTasks(t *testing.T, name string, got []*models.Task, want []*models.Task) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: Expected %d tasks, got %d", name, len(want), len(got))
+	}
+	sameTime := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	sameString := func(a, b *string) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Title != w.Title || g.Status != w.Status || !sameString(g.ID, w.ID) || !sameString(g.Notes, w.Notes) || !sameString(g.Parent, w.Parent) {
+			t.Errorf("%s: Expected task %+v, got %+v", name, w, g)
+		}
+		if !sameTime(g.Due, w.Due) || !sameTime(g.Completed, w.Completed) || !sameTime(g.Updated, w.Updated) {
+			t.Errorf("%s: Expected the times of task '%s' back, got %v %v %v", name, w.Title, g.Due, g.Completed, g.Updated)
+		}
+		assertSameTasks(t, name, g.Children, w.Children)
+	}
+}
+
+func TestDecodeHandWrittenYAML(t *testing.T) {
+	data := `# Exported by hand
+---
+title: 'Week''s plan'   # a comment
+tasks:
+- title: Call Alice
+  due: 2026-10-21T00:00:00Z
+  notes: >
+    Ask about
+    the budget
+
+    and the trip
+- title: "Pay rent"
+  status: completed
+  due: 2026-10-20
+  children:
+    - title: Bank
+      notes: |
+        line 1
+          line 2
+    - title:
+        continued plain
+        title
+`
+	list, err := YAML{}.Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if list.Title != "Week's plan" || len(list.Tasks) != 2 {
+		t.Fatalf("Expected the plan with 2 tasks, got %+v", list)
+	}
+	call := list.Tasks[0]
+	if call.Status != "needsAction" || call.DueDate() != "2026-10-21" || call.Notes == nil || *call.Notes != "Ask about the budget\nand the trip\n" {
+		t.Errorf("Expected folded notes on an open task, got %+v", call)
+	}
+	rent := list.Tasks[1]
+	if rent.Title != "Pay rent" || rent.Status != "completed" || rent.DueDate() != "2026-10-20" || len(rent.Children) != 2 {
+		t.Fatalf("Expected the completed rent task with 2 subtasks, got %+v", rent)
+	}
+	if notes := rent.Children[0].Notes; notes == nil || *notes != "line 1\n  line 2\n" {
+		t.Errorf("Expected literal notes, got %v", notes)
+	}
+	if rent.Children[1].Title != "continued plain title" {
+		t.Errorf("Expected a multi-line plain title, got %q", rent.Children[1].Title)
+	}
+
+	for _, data := range []string{"title: x\ntasks: [a]\n", "title: x\ntasks:\n- title: A\n  due: next week\n", "title: [x\n"} {
+		if _, err := (YAML{}).Decode([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+
+	// Flow collections are read as YAML, and unquoted dates in titles stay as written
+	list, err = YAML{}.Decode([]byte("title: x\ntasks:\n- {title: A, status: completed}\n- title: 2026-10-20\n"))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(list.Tasks) != 2 || list.Tasks[0].Title != "A" || list.Tasks[0].Status != "completed" || list.Tasks[1].Title != "2026-10-20" {
+		t.Errorf("Expected a flow mapping and a date-like title, got %+v", list.Tasks)
+	}
+}
+
+func TestTodoTxt(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 10, 14, 0, 0, 0, 0, time.Local)
+	list := &models.TaskList{
+		Title: "Weekly Groceries",
+		Tasks: []*models.Task{
+			{ID: strPtr("t1"), Title: "Buy milk @store", Status: "needsAction", Due: &due, Notes: strPtr("Oat")},
+			{ID: strPtr("t2"), Title: "Pay", Status: "completed", Completed: &completed, Children: []*models.Task{
+				{ID: strPtr("t3"), Title: "Keep receipt", Status: "needsAction"},
+			}},
+		},
+	}
+
+	data, err := TodoTxt{}.Encode(list)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected := "Buy milk @store +Weekly_Groceries due:2026-10-20 gtasks:t1\n" +
+		"x 2026-10-14 Pay +Weekly_Groceries gtasks:t2\n" +
+		"Keep receipt +Weekly_Groceries gtasks:t3 parent:t2\n"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+	}
+
+	decoded, err := TodoTxt{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Title != "Weekly Groceries" || len(decoded.Tasks) != 2 {
+		t.Fatalf("Expected the list with 2 top-level tasks, got %+v", decoded)
+	}
+	milk := decoded.Tasks[0]
+	if milk.Title != "Buy milk @store" || milk.DueDate() != "2026-10-20" || *milk.ID != "t1" || milk.Notes != nil {
+		t.Errorf("Expected milk with its context, due date and ID, got %+v", milk)
+	}
+	pay := decoded.Tasks[1]
+	if pay.Status != "completed" || pay.Completed == nil || !pay.Completed.Equal(completed) {
+		t.Errorf("Expected pay to be completed on 2026-10-14, got %+v", pay)
+	}
+	if len(pay.Children) != 1 || pay.Children[0].Title != "Keep receipt" || *pay.Children[0].Parent != "t2" {
+		t.Errorf("Expected the receipt to be a subtask of pay, got %+v", pay.Children)
+	}
+}
+
+func TestTodoTxtDecode(t *testing.T) {
+	data := "(A) 2026-10-01 Call Bob +Work +Phone due:2026-10-02\n\nx 2026-10-03 2026-10-01 Write report +Work\nx Unknown date parent:missing\n"
+
+	list, err := TodoTxt{}.Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if list.Title != "Work" || len(list.Tasks) != 3 {
+		t.Fatalf("Expected the Work list with 3 tasks, got %+v", list)
+	}
+	if call := list.Tasks[0]; call.Title != "(A) Call Bob +Phone" || call.DueDate() != "2026-10-02" {
+		t.Errorf("Expected the priority and other projects to stay in the title, got %+v", call)
+	}
+	if report := list.Tasks[1]; report.Title != "Write report" || report.Completed == nil || report.Completed.Format(models.DateLayout) != "2026-10-03" {
+		t.Errorf("Expected the completion date without the creation date, got %+v", report)
+	}
+	if unknown := list.Tasks[2]; unknown.Status != "completed" || unknown.Completed != nil || unknown.Parent != nil {
+		t.Errorf("Expected a completed top-level task without a date, got %+v", unknown)
+	}
+}
+
+func TestTodoTxtEscapesTitles(t *testing.T) {
+	titles := []string{"x marks the spot", "2026-10-01 meeting notes", "(B) 2026-10-01 review", "Plan +Trip with Bob", "Call about due:friday", "Ask for gtasks:help and parent:advice", `\ back\slash`, "Shop for +Groceries"}
+	list := &models.TaskList{Title: "Groceries"}
+	for _, title := range titles {
+		list.Tasks = append(list.Tasks, &models.Task{Title: title, Status: "needsAction"})
+	}
+
+	data, err := TodoTxt{}.Encode(list)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := TodoTxt{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Title != "Groceries" || len(decoded.Tasks) != len(titles) {
+		t.Fatalf("Expected the Groceries list with %d tasks, got %+v\n%s", len(titles), decoded, data)
+	}
+	for i, task := range decoded.Tasks {
+		if task.Title != titles[i] || task.Status != "needsAction" || task.Due != nil || task.ID != nil || task.Parent != nil {
+			t.Errorf("Expected the open task '%s' back, got %+v", titles[i], task)
+		}
+	}
+}
+
+func TestICalRoundTrip(t *testing.T) {
+	list := fullList()
+	list.Tasks[0].Title = strings.Repeat("Long title; with, commas and ünïcödé ", 4)
+
+	data, err := ICal{}.Encode(list)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines to be folded at 75 octets, got %q", line)
+		}
+	}
+	if !strings.Contains(string(data), "RELATED-TO;RELTYPE=PARENT:t2\r\n") || !strings.Contains(string(data), "DUE;VALUE=DATE:20261020\r\n") {
+		t.Errorf("Expected the subtask relation and the due date, got\n%s", data)
+	}
+
+	decoded, err := ICal{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Title != "yes" || *decoded.ID != "list1" {
+		t.Errorf("Expected the list title and ID back, got %+v", decoded)
+	}
+	// iCalendar has no carriage returns in text, nor fractions of seconds
+	*list.Tasks[1].Notes = " leading space\ttab\n"
+	updated := list.Tasks[0].Updated.Truncate(time.Second)
+	list.Tasks[0].Updated = &updated
+	assertSameTasks(t, "ical", decoded.Tasks, list.Tasks)
+}
+
+func TestICalDecode(t *testing.T) {
+	data := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nSUMMARY:Not a task\nEND:VEVENT\n" +
+		"BEGIN:VTODO\nUID:child@example.com\nSUMMARY:Child\nRELATED-TO:parent@example.com\n" +
+		"DUE;TZID=Europe/Berlin:20261020T233000\nEND:VTODO\n" +
+		"BEGIN:VTODO\nUID:parent@example.com\nSUMMARY:Par\n ent\nSTATUS:COMPLETED\n" +
+		"BEGIN:VALARM\nDESCRIPTION:Alarm\nEND:VALARM\nEND:VTODO\nEND:VCALENDAR\n"
+
+	list, err := ICal{}.Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(list.Tasks) != 1 {
+		t.Fatalf("Expected a single top-level task, got %+v", list.Tasks)
+	}
+	parent := list.Tasks[0]
+	if parent.Title != "Parent" || parent.Status != "completed" || parent.Notes != nil || parent.ID != nil {
+		t.Errorf("Expected the completed parent without notes or Google ID, got %+v", parent)
+	}
+	if len(parent.Children) != 1 || parent.Children[0].DueDate() != "2026-10-20" {
+		t.Errorf("Expected the child due on its local date, got %+v", parent.Children)
+	}
+
+	if _, err := (ICal{}).Decode([]byte("BEGIN:VCALENDAR\nBEGIN:VTODO\n")); err == nil {
+		t.Errorf("Expected an error for an unterminated calendar")
+	}
+}
+
+func TestOrgRoundTrip(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 10, 14, 9, 30, 0, 0, time.Local)
+	list := &models.TaskList{
+		ID:    strPtr("list1"),
+		Title: "Groceries",
+		Tasks: []*models.Task{
+			{ID: strPtr("t1"), Title: "Buy milk", Status: "needsAction", Due: &due, Notes: strPtr("Oat\n\n* not a headline")},
+			{ID: strPtr("t2"), Title: "Pay", Status: "completed", Completed: &completed, Children: []*models.Task{
+				{ID: strPtr("t3"), Title: "TODO later", Status: "needsAction", Parent: strPtr("t2"), Children: []*models.Task{
+					{Title: "Deep", Status: "completed", Parent: strPtr("t3"), Notes: strPtr("  indented")},
+				}},
+			}},
+		},
+		Content: []*models.ContentBlock{{Text: "Shared with the family."}},
+	}
+
+	data, err := Org{}.Encode(list)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected := `:PROPERTIES:
+:GTASKS_LIST_ID: list1
+:END:
+#+TITLE: Groceries
+
+Shared with the family.
+
+* TODO Buy milk
+  DEADLINE: <2026-10-20 Tue>
+  :PROPERTIES:
+  :GTASKS_ID: t1
+  :END:
+  Oat
+
+  * not a headline
+* DONE Pay
+  CLOSED: [2026-10-14 Wed 09:30]
+  :PROPERTIES:
+  :GTASKS_ID: t2
+  :END:
+** TODO TODO later
+   :PROPERTIES:
+   :GTASKS_ID: t3
+   :END:
+*** DONE Deep
+      indented
+`
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+	}
+
+	decoded, err := Org{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Title != "Groceries" || *decoded.ID != "list1" || len(decoded.Content) != 1 || decoded.Content[0].Text != "Shared with the family." {
+		t.Errorf("Expected the list title, ID and content back, got %+v", decoded)
+	}
+	assertSameTasks(t, "org", decoded.Tasks, list.Tasks)
+}
+
+func TestOrgDecode(t *testing.T) {
+	data := `#+TITLE: Planning
+#+TODO: NEXT WAIT(w) | FINISHED(f) CANCELED
+
+Some intro.
+* Project
+** NEXT Write draft                                           :work:
+SCHEDULED: <2026-10-18 Sun> DEADLINE: <2026-10-21 Wed 10:00 +1w>
+First line of notes.
+** FINISHED Review
+CLOSED: [2026-10-15 Thu]
+*not a headline*
+* TODO Not a keyword here
+`
+	list, err := Org{}.Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if list.Title != "Planning" || len(list.Tasks) != 2 {
+		t.Fatalf("Expected the planning list with 2 top-level tasks, got %+v", list)
+	}
+	if len(list.Content) != 1 || list.Content[0].Text != "Some intro." {
+		t.Errorf("Expected the intro as content, without keyword lines, got %+v", list.Content)
+	}
+	project := list.Tasks[0]
+	if project.Title != "Project" || project.Status != "needsAction" || len(project.Children) != 2 {
+		t.Fatalf("Expected a plain headline as an open task with 2 subtasks, got %+v", project)
+	}
+	draft := project.Children[0]
+	if draft.Title != "Write draft                                           :work:" || draft.DueDate() != "2026-10-21" || *draft.Notes != "First line of notes." {
+		t.Errorf("Expected the draft with its deadline and notes, got %+v", draft)
+	}
+	review := project.Children[1]
+	if review.Status != "completed" || review.Completed == nil || review.Completed.Format(models.DateLayout) != "2026-10-15" || *review.Notes != "*not a headline*" {
+		t.Errorf("Expected the review to be completed on 2026-10-15, got %+v", review)
+	}
+	if last := list.Tasks[1]; last.Title != "TODO Not a keyword here" || last.Status != "needsAction" {
+		t.Errorf("Expected TODO to be part of the title, got %+v", last)
+	}
+}
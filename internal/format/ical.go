@@ -0,0 +1,376 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gtasks2md/internal/models"
+)
+
+// ICal writes a list as an iCalendar (RFC 5545) calendar with one VTODO per task. Subtasks point
+// to their parent's UID with RELATED-TO, and Google Tasks IDs are kept in X-GTASKS-ID properties,
+// since UIDs from other applications are not Google Tasks IDs.
+type ICal struct{}
+
+func init() {
+	Register(ICal{})
+}
+
+const (
+	icalDateLayout     = "20060102"
+	icalDateTimeLayout = "20060102T150405"
+	// icalLineLength is the length in octets at which content lines are folded.
+	icalLineLength = 75
+)
+
+func (ICal) Name() string {
+	return "ical"
+}
+
+func (ICal) Extension() string {
+	return ".ics"
+}
+
+func (ICal) Capabilities() Capabilities {
+	return FullCapabilities
+}
+
+func (ICal) Encode(tasklist *models.TaskList) ([]byte, error) {
+	var lines []string
+	add := func(name string, value string) {
+		lines = append(lines, foldICalLine(name+":"+value)...)
+	}
+
+	add("BEGIN", "VCALENDAR")
+	add("VERSION", "2.0")
+	add("PRODID", "-//gtasks2md//gtasks2md//EN")
+	add("X-WR-CALNAME", escapeICalText(tasklist.Title))
+	if tasklist.ID != nil && *tasklist.ID != "" {
+		add("X-GTASKS-LIST-ID", escapeICalText(*tasklist.ID))
+	}
+
+	stamp := time.Now().UTC()
+	if tasklist.SyncedAt != nil {
+		stamp = tasklist.SyncedAt.UTC()
+	}
+	count := 0
+	var appendTask func(task *models.Task, parentUID string)
+	appendTask = func(task *models.Task, parentUID string) {
+		count++
+		uid := fmt.Sprintf("task-%d@gtasks2md", count)
+		if task.ID != nil && *task.ID != "" {
+			uid = *task.ID
+		}
+		modified := stamp
+		if task.Updated != nil {
+			modified = task.Updated.UTC()
+		}
+
+		add("BEGIN", "VTODO")
+		add("UID", escapeICalText(uid))
+		add("DTSTAMP", modified.Format(icalDateTimeLayout)+"Z")
+		if task.ID != nil && *task.ID != "" {
+			add("X-GTASKS-ID", escapeICalText(*task.ID))
+		}
+		add("SUMMARY", escapeICalText(task.Title))
+		if task.Notes != nil {
+			add("DESCRIPTION", escapeICalText(*task.Notes))
+		}
+		if task.Status == "completed" {
+			add("STATUS", "COMPLETED")
+			if task.Completed != nil {
+				add("COMPLETED", task.Completed.UTC().Format(icalDateTimeLayout)+"Z")
+			}
+		} else {
+			add("STATUS", "NEEDS-ACTION")
+		}
+		if task.Due != nil {
+			add("DUE;VALUE=DATE", task.Due.Format(icalDateLayout))
+		}
+		if task.Updated != nil {
+			add("LAST-MODIFIED", modified.Format(icalDateTimeLayout)+"Z")
+		}
+		if parentUID != "" {
+			add("RELATED-TO;RELTYPE=PARENT", escapeICalText(parentUID))
+		}
+		add("END", "VTODO")
+
+		for _, subtask := range task.Children {
+			appendTask(subtask, uid)
+		}
+	}
+	for _, task := range tasklist.Tasks {
+		appendTask(task, "")
+	}
+	add("END", "VCALENDAR")
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n"), nil
+}
+
+// icalProperty is a content line of an iCalendar file.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode reads the VTODOs of the first calendar of a file, in their order. Other components,
+// such as events and the alarms of tasks, are skipped. A VTODO whose RELATED-TO parent is
+// another VTODO of the file becomes its subtask.
+func (ICal) Decode(data []byte) (*models.TaskList, error) {
+	tasklist := &models.TaskList{}
+	byUID := make(map[string]*models.Task)
+	parentUIDs := make(map[*models.Task]string)
+	var tasks []*models.Task
+
+	var components []string
+	var task *models.Task
+	found := false
+	for _, line := range unfoldICalLines(string(data)) {
+		if found {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseICalLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.value)
+			if len(components) == 0 && component != "VCALENDAR" {
+				return nil, fmt.Errorf("expected BEGIN:VCALENDAR, got BEGIN:%s", prop.value)
+			}
+			components = append(components, component)
+			if component == "VTODO" && len(components) == 2 {
+				task = &models.Task{Status: "needsAction"}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("unexpected END:%s", prop.value)
+			}
+			components = components[:len(components)-1]
+			if task != nil && len(components) == 1 {
+				tasks = append(tasks, task)
+				task = nil
+			}
+			if len(components) == 0 {
+				found = true
+			}
+			continue
+		}
+
+		switch {
+		case len(components) == 1:
+			switch prop.name {
+			case "X-WR-CALNAME":
+				tasklist.Title = unescapeICalText(prop.value)
+			case "X-GTASKS-LIST-ID":
+				id := unescapeICalText(prop.value)
+				tasklist.ID = &id
+			}
+		case task != nil && len(components) == 2:
+			if err := applyICalProperty(task, prop, byUID, parentUIDs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		if len(components) == 0 {
+			return nil, fmt.Errorf("no VCALENDAR found")
+		}
+		return nil, fmt.Errorf("missing END:%s", components[len(components)-1])
+	}
+
+	for _, task := range tasks {
+		parent := byUID[parentUIDs[task]]
+		if parent == nil || isICalAncestor(task, parent, byUID, parentUIDs) {
+			tasklist.Tasks = append(tasklist.Tasks, task)
+			continue
+		}
+		task.Parent = parent.ID
+		parent.Children = append(parent.Children, task)
+	}
+	return tasklist, nil
+}
+
+// applyICalProperty sets the field of a task read from a VTODO property.
+func applyICalProperty(task *models.Task, prop *icalProperty, byUID map[string]*models.Task, parentUIDs map[*models.Task]string) error {
+	switch prop.name {
+	case "UID":
+		byUID[unescapeICalText(prop.value)] = task
+	case "X-GTASKS-ID":
+		id := unescapeICalText(prop.value)
+		task.ID = &id
+	case "SUMMARY":
+		task.Title = unescapeICalText(prop.value)
+	case "DESCRIPTION":
+		notes := unescapeICalText(prop.value)
+		task.Notes = &notes
+	case "STATUS":
+		if strings.EqualFold(prop.value, "COMPLETED") {
+			task.Status = "completed"
+		}
+	case "COMPLETED":
+		completed, err := parseICalTime(prop)
+		if err != nil {
+			return err
+		}
+		task.Status = "completed"
+		task.Completed = &completed
+	case "DUE":
+		due, err := parseICalTime(prop)
+		if err != nil {
+			return err
+		}
+		// Google Tasks keeps only the date
+		date := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+		task.Due = &date
+	case "LAST-MODIFIED":
+		updated, err := parseICalTime(prop)
+		if err != nil {
+			return err
+		}
+		task.Updated = &updated
+	case "RELATED-TO":
+		if reltype := prop.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			parentUIDs[task] = unescapeICalText(prop.value)
+		}
+	}
+	return nil
+}
+
+// isICalAncestor reports whether task is parent or one of its ancestors, which would make
+// the parent a subtask of itself.
+func isICalAncestor(task *models.Task, parent *models.Task, byUID map[string]*models.Task, parentUIDs map[*models.Task]string) bool {
+	for steps := 0; parent != nil && steps <= len(parentUIDs); steps++ {
+		if parent == task {
+			return true
+		}
+		parent = byUID[parentUIDs[parent]]
+	}
+	return false
+}
+
+// parseICalTime reads a DATE or DATE-TIME value. Times in UTC end with Z; other times are in
+// the time zone of their TZID parameter, if Go knows it, or else local.
+func parseICalTime(prop *icalProperty) (time.Time, error) {
+	value := prop.value
+	location := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			location = loc
+		}
+	}
+
+	layout := icalDateTimeLayout
+	switch {
+	case len(value) == len(icalDateLayout):
+		layout, location = icalDateLayout, time.UTC
+	case strings.HasSuffix(value, "Z"):
+		value, location = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+	t, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s value %s", prop.name, prop.value)
+	}
+	return t, nil
+}
+
+// parseICalLine splits a content line into its name, parameters and value.
+func parseICalLine(line string) (*icalProperty, error) {
+	prop := &icalProperty{params: make(map[string]string)}
+	quoted := false
+	start := 0
+	var param string
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';' || c == ':':
+			part := line[start:i]
+			if prop.name == "" {
+				prop.name = strings.ToUpper(part)
+			} else if param != "" {
+				prop.params[param] = strings.Trim(part, `"`)
+			}
+			param = ""
+			start = i + 1
+			if c == ':' {
+				prop.value = line[i+1:]
+				if prop.name == "" {
+					return nil, fmt.Errorf("missing property name")
+				}
+				return prop, nil
+			}
+		case c == '=' && param == "" && prop.name != "":
+			param = strings.ToUpper(line[start:i])
+			start = i + 1
+		}
+	}
+	return nil, fmt.Errorf("missing ':' in %q", line)
+}
+
+// foldICalLine splits a content line into lines of at most 75 octets, continued lines
+// starting with a space. Lines are only split between characters.
+func foldICalLine(line string) []string {
+	var lines []string
+	for len(line) > icalLineLength {
+		cut := icalLineLength
+		for cut > 1 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		lines = append(lines, line[:cut])
+		line = " " + line[cut:]
+	}
+	return append(lines, line)
+}
+
+// unfoldICalLines joins folded content lines, accepting both CRLF and LF line endings.
+func unfoldICalLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapeICalText escapes a TEXT value. Carriage returns, which iCalendar cannot escape,
+// are dropped from line endings.
+func escapeICalText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return replacer.Replace(text)
+}
+
+// unescapeICalText reverses escapeICalText.
+func unescapeICalText(text string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			switch text[i] {
+			case 'n', 'N':
+				builder.WriteByte('\n')
+			default:
+				builder.WriteByte(text[i])
+			}
+			continue
+		}
+		builder.WriteByte(text[i])
+	}
+	return builder.String()
+}
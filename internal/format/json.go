@@ -0,0 +1,46 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gtasks2md/internal/models"
+)
+
+// JSON writes every field of a task list, with subtasks nested under their parents.
+type JSON struct{}
+
+func init() {
+	Register(JSON{})
+}
+
+func (JSON) Name() string {
+	return "json"
+}
+
+func (JSON) Extension() string {
+	return ".json"
+}
+
+func (JSON) Capabilities() Capabilities {
+	return FullCapabilities
+}
+
+func (JSON) Encode(tasklist *models.TaskList) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(newListDocument(tasklist)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (JSON) Decode(data []byte) (*models.TaskList, error) {
+	var doc listDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.taskList(), nil
+}
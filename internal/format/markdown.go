@@ -0,0 +1,41 @@
+package format
+
+import (
+	"gtasks2md/internal/markdown"
+	"gtasks2md/internal/models"
+)
+
+// Markdown is the Markdown task list format, written with the given serializer options.
+type Markdown struct {
+	Options markdown.SerializerOptions
+}
+
+func init() {
+	Register(Markdown{})
+}
+
+func (Markdown) Name() string {
+	return "markdown"
+}
+
+func (Markdown) Extension() string {
+	return ".md"
+}
+
+// Capabilities reports what Markdown keeps; completion dates are only written with
+// Options.CompletionDates, and then only as a date.
+func (m Markdown) Capabilities() Capabilities {
+	return Capabilities{TaskIDs: true, Subtasks: true, TitleSpacing: true, Notes: true, NoteSpacing: true, DueDates: true, CompletionDates: m.Options.CompletionDates}
+}
+
+func (m Markdown) Encode(tasklist *models.TaskList) ([]byte, error) {
+	serializer := markdown.NewSerializer(tasklist)
+	serializer.Options = m.Options
+	return []byte(serializer.Serialize()), nil
+}
+
+func (m Markdown) Decode(data []byte) (*models.TaskList, error) {
+	parser := markdown.NewParser(string(data))
+	parser.Options = m.Options.ParserOptions()
+	return parser.Parse(), nil
+}
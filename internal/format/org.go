@@ -0,0 +1,330 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gtasks2md/internal/models"
+)
+
+// Org writes a list as an Org-mode outline: each task is a TODO or DONE headline, with
+// subtasks one level deeper. A planning line holds the due date as DEADLINE and the
+// completion time as CLOSED, a property drawer the Google Tasks ID, and the notes follow
+// as body text. Everything under a headline is indented to its level.
+type Org struct{}
+
+func init() {
+	Register(Org{})
+}
+
+var (
+	orgHeadlinePattern = regexp.MustCompile(`^(\*+)(?:[ \t]+(.*?))?[ \t]*$`)
+	orgPlanningPattern = regexp.MustCompile(`^[ \t]*(?:(?:DEADLINE|SCHEDULED|CLOSED):[ \t]*[<\[][^>\]]*[>\]][ \t]*)+$`)
+	// orgTimestampPattern matches a planning keyword and its timestamp, with the date
+	// in the third group and the time, if any, in the fourth.
+	orgTimestampPattern = regexp.MustCompile(`(DEADLINE|SCHEDULED|CLOSED):[ \t]*[<\[](\d{4}-\d{2}-\d{2})((?:[ \t]+[^\d \t>\]]+)?(?:[ \t]+(\d{1,2}:\d{2}))?)[^>\]]*[>\]]`)
+	orgPropertyPattern  = regexp.MustCompile(`^[ \t]*:([^:\s]+):(?:[ \t]+(.*?))?[ \t]*$`)
+	orgKeywordPattern   = regexp.MustCompile(`^#\+([A-Za-z_]+):[ \t]*(.*?)[ \t]*$`)
+)
+
+const (
+	orgIDProperty     = "GTASKS_ID"
+	orgListIDProperty = "GTASKS_LIST_ID"
+)
+
+func (Org) Name() string {
+	return "org"
+}
+
+func (Org) Extension() string {
+	return ".org"
+}
+
+// Capabilities leaves out the spacing of titles and notes: titles are written on a single line,
+// and blank lines around notes are read as the spacing of the outline.
+func (Org) Capabilities() Capabilities {
+	return Capabilities{TaskIDs: true, Subtasks: true, Notes: true, DueDates: true, CompletionDates: true}
+}
+
+func (Org) Encode(tasklist *models.TaskList) ([]byte, error) {
+	var lines []string
+	if tasklist.ID != nil && *tasklist.ID != "" {
+		lines = append(lines, ":PROPERTIES:", fmt.Sprintf(":%s: %s", orgListIDProperty, *tasklist.ID), ":END:")
+	}
+	lines = append(lines, "#+TITLE: "+orgLine(tasklist.Title))
+	for _, block := range tasklist.Content {
+		if block.After == nil {
+			lines = append(lines, "", block.Text)
+		}
+	}
+	if len(tasklist.Tasks) > 0 {
+		lines = append(lines, "")
+	}
+
+	var appendTask func(task *models.Task, level int)
+	appendTask = func(task *models.Task, level int) {
+		keyword := "TODO"
+		if task.Status == "completed" {
+			keyword = "DONE"
+		}
+		headline := strings.Repeat("*", level) + " " + keyword
+		if title := orgLine(task.Title); title != "" {
+			headline += " " + title
+		}
+		lines = append(lines, headline)
+
+		indent := strings.Repeat(" ", level+1)
+		var planning []string
+		if task.Status == "completed" && task.Completed != nil {
+			planning = append(planning, "CLOSED: ["+task.Completed.Local().Format("2006-01-02 Mon 15:04")+"]")
+		}
+		if task.Due != nil {
+			planning = append(planning, "DEADLINE: <"+task.Due.Format("2006-01-02 Mon")+">")
+		}
+		if len(planning) > 0 {
+			lines = append(lines, indent+strings.Join(planning, " "))
+		}
+		if task.ID != nil && *task.ID != "" {
+			lines = append(lines, indent+":PROPERTIES:", fmt.Sprintf("%s:%s: %s", indent, orgIDProperty, *task.ID), indent+":END:")
+		}
+		if task.Notes != nil && *task.Notes != "" {
+			for _, line := range strings.Split(*task.Notes, "\n") {
+				if line != "" {
+					line = indent + line
+				}
+				lines = append(lines, line)
+			}
+		}
+
+		for _, subtask := range task.Children {
+			appendTask(subtask, level+1)
+		}
+	}
+	for _, task := range tasklist.Tasks {
+		appendTask(task, 1)
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// orgLine puts a title on a single line.
+func orgLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Decode reads an Org file. Every headline is a task: TODO and DONE, or the keywords of the
+// #+TODO lines, set its status, and headlines without one are open. Text before the first
+// headline, other than the title, the list ID and the keyword declarations, is kept as content.
+func (Org) Decode(data []byte) (*models.TaskList, error) {
+	tasklist := &models.TaskList{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	openKeywords, doneKeywords := orgTodoKeywords(lines)
+
+	// The tasks of the current branch and their levels
+	var branch []*models.Task
+	var levels []int
+	var preamble []string
+	var body []string
+	var task *models.Task
+
+	finishTask := func() {
+		if task == nil {
+			return
+		}
+		notes := strings.Join(trimBlank(body), "\n")
+		if notes != "" {
+			task.Notes = &notes
+		}
+		body = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		match := orgHeadlinePattern.FindStringSubmatch(line)
+		if match == nil || (match[2] == "" && !strings.HasPrefix(line, strings.Repeat("*", len(match[1]))+" ")) {
+			if task == nil {
+				preamble = append(preamble, line)
+			} else {
+				body = append(body, dedentOrg(line, levels[len(levels)-1]+1))
+			}
+			continue
+		}
+
+		finishTask()
+		level := len(match[1])
+		task = &models.Task{Status: "needsAction"}
+		keyword, title, _ := strings.Cut(match[2], " ")
+		switch {
+		case openKeywords[keyword]:
+			task.Title = strings.TrimSpace(title)
+		case doneKeywords[keyword]:
+			task.Status = "completed"
+			task.Title = strings.TrimSpace(title)
+		default:
+			task.Title = match[2]
+		}
+
+		// The planning line and the property drawer directly follow the headline
+		if i+1 < len(lines) && orgPlanningPattern.MatchString(lines[i+1]) {
+			i++
+			if err := applyOrgPlanning(task, lines[i]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		properties, end := readOrgDrawer(lines, i+1)
+		if id := properties[orgIDProperty]; id != "" {
+			task.ID = &id
+		}
+		i = end - 1
+
+		for len(levels) > 0 && levels[len(levels)-1] >= level {
+			branch, levels = branch[:len(branch)-1], levels[:len(levels)-1]
+		}
+		if len(branch) == 0 {
+			tasklist.Tasks = append(tasklist.Tasks, task)
+		} else {
+			parent := branch[len(branch)-1]
+			task.Parent = parent.ID
+			parent.Children = append(parent.Children, task)
+		}
+		branch, levels = append(branch, task), append(levels, level)
+	}
+	finishTask()
+
+	// The preamble holds the list's property drawer and keywords, and maybe other text
+	preamble = trimBlank(preamble)
+	properties, start := readOrgDrawer(preamble, 0)
+	if id := properties[orgListIDProperty]; id != "" {
+		tasklist.ID = &id
+	}
+	var content []string
+	for _, line := range preamble[start:] {
+		match := orgKeywordPattern.FindStringSubmatch(line)
+		switch {
+		case match != nil && strings.EqualFold(match[1], "TITLE"):
+			tasklist.Title = match[2]
+		case match != nil && isOrgTodoKeyword(match[1]):
+			// Files are written back with TODO and DONE only
+		default:
+			content = append(content, line)
+		}
+	}
+	if text := strings.Join(trimBlank(content), "\n"); text != "" {
+		tasklist.Content = []*models.ContentBlock{{Text: text}}
+	}
+	return tasklist, nil
+}
+
+// orgTodoKeywords returns the open and done keywords of a file: TODO and DONE, or those of
+// its #+TODO lines, as in "#+TODO: TODO NEXT(n) | DONE CANCELED". Without a bar, the last
+// keyword of a line is the done one.
+func orgTodoKeywords(lines []string) (map[string]bool, map[string]bool) {
+	openKeywords := map[string]bool{"TODO": true}
+	doneKeywords := map[string]bool{"DONE": true}
+	declared := false
+	for _, line := range lines {
+		match := orgKeywordPattern.FindStringSubmatch(line)
+		if match == nil || !isOrgTodoKeyword(match[1]) {
+			continue
+		}
+		if !declared {
+			openKeywords, doneKeywords = make(map[string]bool), make(map[string]bool)
+			declared = true
+		}
+
+		open, done, found := strings.Cut(match[2], "|")
+		if !found {
+			fields := strings.Fields(match[2])
+			if len(fields) == 0 {
+				continue
+			}
+			open, done = strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+		}
+		for _, keyword := range strings.Fields(open) {
+			// Drop fast access keys, as in "NEXT(n)"
+			name, _, _ := strings.Cut(keyword, "(")
+			openKeywords[name] = true
+		}
+		for _, keyword := range strings.Fields(done) {
+			name, _, _ := strings.Cut(keyword, "(")
+			doneKeywords[name] = true
+		}
+	}
+	return openKeywords, doneKeywords
+}
+
+// isOrgTodoKeyword reports whether a #+ keyword declares the TODO keywords of a file.
+func isOrgTodoKeyword(name string) bool {
+	switch strings.ToUpper(name) {
+	case "TODO", "SEQ_TODO", "TYP_TODO":
+		return true
+	}
+	return false
+}
+
+// applyOrgPlanning sets the due date and completion time of a task from its planning line.
+func applyOrgPlanning(task *models.Task, line string) error {
+	for _, match := range orgTimestampPattern.FindAllStringSubmatch(line, -1) {
+		switch match[1] {
+		case "DEADLINE":
+			due, err := models.ParseDate(match[2])
+			if err != nil {
+				return fmt.Errorf("invalid DEADLINE %s", match[2])
+			}
+			task.Due = due
+		case "CLOSED":
+			value, layout := match[2], models.DateLayout
+			if match[4] != "" {
+				value, layout = value+" "+match[4], models.DateLayout+" 15:04"
+			}
+			completed, err := time.ParseInLocation(layout, value, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid CLOSED %s", value)
+			}
+			task.Completed = &completed
+		}
+	}
+	return nil
+}
+
+// readOrgDrawer reads the property drawer starting at lines[start], if there is one, and
+// returns its properties and the index of the line following it.
+func readOrgDrawer(lines []string, start int) (map[string]string, int) {
+	properties := make(map[string]string)
+	if start >= len(lines) || !strings.EqualFold(strings.TrimSpace(lines[start]), ":PROPERTIES:") {
+		return properties, start
+	}
+	for i := start + 1; i < len(lines); i++ {
+		if strings.EqualFold(strings.TrimSpace(lines[i]), ":END:") {
+			return properties, i + 1
+		}
+		match := orgPropertyPattern.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		properties[strings.ToUpper(match[1])] = match[2]
+	}
+	// An unterminated drawer is body text
+	return make(map[string]string), start
+}
+
+// dedentOrg removes up to the given number of spaces indenting a line.
+func dedentOrg(line string, indent int) string {
+	for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// trimBlank drops the blank lines at the start and end of lines.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,178 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+
+	"gtasks2md/internal/models"
+)
+
+// TodoTxt writes one task per line in the todo.txt convention, as in
+// "x 2026-10-14 Buy milk +Groceries due:2026-10-20 gtasks:ID". The list title is the +project
+// of every task, with spaces written as underscores. Subtasks follow their parent, pointing
+// to it with a parent: key, so those of tasks without an ID are moved to the top level. todo.txt
+// has no notes, so they are not kept. Title words that would be read as something else are
+// escaped with a backslash.
+type TodoTxt struct{}
+
+func init() {
+	Register(TodoTxt{})
+}
+
+var (
+	// todoDatePattern matches a date in todo.txt.
+	todoDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	// priorityPattern matches the priority starting an open task.
+	priorityPattern = regexp.MustCompile(`^\([A-Z]\)$`)
+)
+
+func (TodoTxt) Name() string {
+	return "todotxt"
+}
+
+func (TodoTxt) Extension() string {
+	return ".txt"
+}
+
+func (TodoTxt) Capabilities() Capabilities {
+	return Capabilities{TaskIDs: true, Subtasks: true, DueDates: true, CompletionDates: true, SubtasksNeedIDs: true}
+}
+
+func (TodoTxt) Encode(tasklist *models.TaskList) ([]byte, error) {
+	project := ""
+	if fields := strings.Fields(tasklist.Title); len(fields) > 0 {
+		project = "+" + strings.Join(fields, "_")
+	}
+
+	var lines []string
+	var appendTask func(task *models.Task, parent *models.Task)
+	appendTask = func(task *models.Task, parent *models.Task) {
+		var parts []string
+		if task.Status == "completed" {
+			parts = append(parts, "x")
+			if task.Completed != nil {
+				parts = append(parts, task.CompletionDate())
+			}
+		}
+		// todo.txt is line based, so line breaks in titles become spaces
+		parts = append(parts, escapeTodoTitle(strings.Fields(task.Title))...)
+		if project != "" {
+			parts = append(parts, project)
+		}
+		if task.Due != nil {
+			parts = append(parts, "due:"+task.DueDate())
+		}
+		if task.ID != nil && *task.ID != "" {
+			parts = append(parts, "gtasks:"+*task.ID)
+		}
+		if parent != nil && parent.ID != nil && *parent.ID != "" {
+			parts = append(parts, "parent:"+*parent.ID)
+		}
+		lines = append(lines, strings.Join(parts, " "))
+
+		for _, subtask := range task.Children {
+			appendTask(subtask, task)
+		}
+	}
+	for _, task := range tasklist.Tasks {
+		appendTask(task, nil)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// escapeTodoTitle escapes the words of a title that Decode would not read back as title words:
+// a leading "x" or date, a date following a leading priority, projects, the keys Decode reads
+// and words already starting with a backslash.
+func escapeTodoTitle(words []string) []string {
+	escaped := make([]string, len(words))
+	for i, word := range words {
+		key, value, _ := strings.Cut(word, ":")
+		switch {
+		case i == 0 && (word == "x" || todoDatePattern.MatchString(word)),
+			i == 1 && priorityPattern.MatchString(words[0]) && todoDatePattern.MatchString(word),
+			strings.HasPrefix(word, "+") && len(word) > 1,
+			(key == "due" || key == "gtasks" || key == "parent") && value != "",
+			strings.HasPrefix(word, `\`):
+			word = `\` + word
+		}
+		escaped[i] = word
+	}
+	return escaped
+}
+
+// Decode reads a todo.txt file. The first +project found names the list and is dropped from
+// the titles of tasks; other projects, contexts, priorities and keys stay in the titles, without
+// the backslash escaping them. A task whose parent: key names a task above it becomes its subtask.
+func (TodoTxt) Decode(data []byte) (*models.TaskList, error) {
+	tasklist := &models.TaskList{}
+	project := ""
+	byID := make(map[string]*models.Task)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		task := &models.Task{Status: "needsAction"}
+		parentID := ""
+		if fields[0] == "x" {
+			task.Status = "completed"
+			fields = fields[1:]
+			if len(fields) > 0 && todoDatePattern.MatchString(fields[0]) {
+				if d, err := models.ParseCompletionDate(fields[0]); err == nil {
+					task.Completed = d
+				}
+				fields = fields[1:]
+			}
+		}
+		var title []string
+		if len(fields) > 0 && priorityPattern.MatchString(fields[0]) {
+			title = append(title, fields[0])
+			fields = fields[1:]
+		}
+		// The creation date has no counterpart in Google Tasks
+		if len(fields) > 0 && todoDatePattern.MatchString(fields[0]) {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			key, value, _ := strings.Cut(field, ":")
+			switch {
+			case project == "" && strings.HasPrefix(field, "+") && len(field) > 1:
+				project = field
+				tasklist.Title = strings.ReplaceAll(field[1:], "_", " ")
+			case field == project:
+			case key == "due" && task.Due == nil && todoDatePattern.MatchString(value):
+				if d, err := models.ParseDate(value); err == nil {
+					task.Due = d
+				} else {
+					title = append(title, field)
+				}
+			case key == "gtasks" && value != "" && task.ID == nil:
+				task.ID = &value
+			case key == "parent" && value != "":
+				parentID = value
+			default:
+				title = append(title, strings.TrimPrefix(field, `\`))
+			}
+		}
+		task.Title = strings.Join(title, " ")
+
+		// Parents come before their subtasks
+		if parent, ok := byID[parentID]; ok {
+			task.Parent = &parentID
+			parent.Children = append(parent.Children, task)
+		} else {
+			tasklist.Tasks = append(tasklist.Tasks, task)
+		}
+		if task.ID != nil {
+			byID[*task.ID] = task
+		}
+	}
+	return tasklist, nil
+}
@@ -0,0 +1,136 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gtasks2md/internal/models"
+)
+
+// YAML writes the same document as JSON, in YAML block style.
+type YAML struct{}
+
+func init() {
+	Register(YAML{})
+}
+
+func (YAML) Name() string {
+	return "yaml"
+}
+
+func (YAML) Extension() string {
+	return ".yaml"
+}
+
+func (YAML) Capabilities() Capabilities {
+	return JSON{}.Capabilities()
+}
+
+// Encode goes through JSON, so that both formats write the same fields in the same order.
+func (YAML) Encode(tasklist *models.TaskList) ([]byte, error) {
+	data, err := JSON{}.Encode(tasklist)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, written in flow style with quoted strings
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle clears the styles of a node and its content, letting the encoder write
+// collections in block style and quote only the strings that need it. Strings that YAML 1.1
+// readers take for booleans stay quoted.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!str" && yaml11Booleans[strings.ToLower(node.Value)] {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// yaml11Booleans are the plain scalars YAML 1.1 reads as booleans, besides true and false.
+var yaml11Booleans = map[string]bool{"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true}
+
+// Decode reads the document through JSON, so that both formats accept the same fields.
+func (YAML) Decode(data []byte) (*models.TaskList, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if node.Kind == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+	value, err := yamlValue(&node)
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return JSON{}.Decode(data)
+}
+
+// yamlValue converts a YAML node to a value json.Marshal can write. Timestamps are kept as
+// written, so that dates are read as the fields expecting them decide.
+func yamlValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		mapping := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			mapping[node.Content[i].Value] = value
+		}
+		return mapping, nil
+	case yaml.SequenceNode:
+		sequence := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, value)
+		}
+		return sequence, nil
+	}
+
+	switch node.ShortTag() {
+	case "!!str", "!!timestamp":
+		return node.Value, nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	return value, nil
+}
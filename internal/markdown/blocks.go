@@ -0,0 +1,291 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// blockKind is the kind of a node of the block structure of a Markdown document.
+type blockKind int
+
+const (
+	documentBlock blockKind = iota
+	listItemBlock
+	paragraphBlock
+	headingBlock
+	codeBlock
+	breakBlock
+)
+
+// block is a node of the block structure of a Markdown document, following the CommonMark
+// block parsing rules for the blocks that matter to task lists. Block quotes and HTML blocks
+// are read as paragraphs, and lists are not represented apart from their items.
+type block struct {
+	kind     blockKind
+	parent   *block
+	children []*block
+
+	// first and last are the indices of the first and last source lines of the block.
+	first, last int
+	// text holds the lines of a leaf block, without the indentation of its containers.
+	text []string
+
+	// width is the indentation of the content of a list item, relative to its container.
+	width int
+	// level is the level of a heading.
+	level int
+	// fence is the opening fence of a fenced code block.
+	fence string
+}
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextPattern        = regexp.MustCompile(`^(=+|-+)[ \t]*$`)
+	thematicBreakPattern = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern         = regexp.MustCompile("^(`{3,}|~{3,})(.*)$")
+	bulletPattern        = regexp.MustCompile(`^([-*+])(?:[ \t]|$)`)
+	orderedPattern       = regexp.MustCompile(`^(\d{1,9})([.)])(?:[ \t]|$)`)
+)
+
+// add appends a child block starting at line i.
+func (b *block) add(kind blockKind, i int) *block {
+	child := &block{kind: kind, parent: b, first: i, last: i}
+	b.children = append(b.children, child)
+	return child
+}
+
+// extend makes a block and its ancestors run at least to line i.
+func (b *block) extend(i int) {
+	for ; b != nil; b = b.parent {
+		b.last = i
+	}
+}
+
+// parseBlocks builds the block structure of the given lines.
+func parseBlocks(lines []string) *block {
+	doc := &block{kind: documentBlock, last: -1}
+	// containers is the chain of open list items, starting with the document
+	containers := []*block{doc}
+	// leaf is the open paragraph or code block, if any
+	var leaf *block
+
+	for i, raw := range lines {
+		line := expandIndent(raw)
+
+		// Match the open list items: each needs its content indentation, or a blank line
+		matched := 1
+		for ; matched < len(containers); matched++ {
+			item := containers[matched]
+			if isBlank(line) {
+				if len(item.children) == 0 && item.first < i {
+					// An item can begin with at most one blank line
+					break
+				}
+				continue
+			}
+			if leadingSpaces(line) < item.width {
+				break
+			}
+			line = line[item.width:]
+		}
+		allMatched := matched == len(containers)
+
+		if allMatched && leaf != nil && leaf.fence != "" {
+			leaf.text = append(leaf.text, line)
+			leaf.extend(i)
+			if closesFence(line, leaf.fence) {
+				leaf = nil
+			}
+			continue
+		}
+
+		paragraph := leaf != nil && leaf.kind == paragraphBlock
+		closeUnmatched := func() {
+			containers = containers[:matched]
+			if leaf != nil && leaf.parent != containers[len(containers)-1] {
+				leaf = nil
+			}
+		}
+
+		// Open new blocks as long as the line starts one
+		started, handled := false, false
+		for !handled {
+			indent := leadingSpaces(line)
+			if indent >= 4 {
+				break
+			}
+			rest := line[indent:]
+			container := containers[matched-1]
+
+			if allMatched && paragraph && leaf.parent == container && setextPattern.MatchString(rest) {
+				leaf.kind = headingBlock
+				leaf.level = 2
+				if rest[0] == '=' {
+					leaf.level = 1
+				}
+				leaf.extend(i)
+				leaf = nil
+				handled = true
+				break
+			}
+
+			switch {
+			case isFence(rest):
+				closeUnmatched()
+				leaf = container.add(codeBlock, i)
+				leaf.fence = fencePattern.FindStringSubmatch(rest)[1]
+				leaf.text = []string{line}
+				leaf.extend(i)
+				handled = true
+				continue
+			case atxHeadingPattern.MatchString(rest):
+				closeUnmatched()
+				match := atxHeadingPattern.FindStringSubmatch(rest)
+				heading := container.add(headingBlock, i)
+				heading.level = len(match[1])
+				heading.text = []string{match[2]}
+				heading.extend(i)
+				leaf = nil
+				handled = true
+				continue
+			case thematicBreakPattern.MatchString(rest):
+				closeUnmatched()
+				container.add(breakBlock, i).extend(i)
+				leaf = nil
+				handled = true
+				continue
+			}
+
+			// Only a list opening inside the paragraph's own container interrupts it; an item
+			// of an enclosing list continues that list
+			width, ok := listMarkerWidth(rest, paragraph && !started && leaf.parent == container)
+			if !ok {
+				break
+			}
+			closeUnmatched()
+			item := container.add(listItemBlock, i)
+			item.width = indent + width
+			item.extend(i)
+			containers = append(containers, item)
+			matched = len(containers)
+			allMatched = true
+			paragraph = false
+			leaf = nil
+			started = true
+			if len(line) < item.width {
+				line = ""
+			} else {
+				line = line[item.width:]
+			}
+		}
+		if handled || (started && isBlank(line)) {
+			continue
+		}
+
+		switch {
+		case isBlank(line):
+			closeUnmatched()
+			if paragraph {
+				leaf = nil
+			}
+			if leaf != nil {
+				leaf.text = append(leaf.text, "")
+			}
+			containers[len(containers)-1].extend(i)
+		case !allMatched && paragraph:
+			// Lazy continuation of the open paragraph
+			leaf.text = append(leaf.text, strings.TrimLeft(line, " "))
+			leaf.extend(i)
+		default:
+			closeUnmatched()
+			container := containers[len(containers)-1]
+			switch {
+			case leaf != nil && leaf.kind == paragraphBlock:
+				leaf.text = append(leaf.text, strings.TrimLeft(line, " "))
+			case leadingSpaces(line) >= 4:
+				if leaf == nil {
+					leaf = container.add(codeBlock, i)
+				}
+				leaf.text = append(leaf.text, line[4:])
+			default:
+				leaf = container.add(paragraphBlock, i)
+				leaf.text = []string{strings.TrimLeft(line, " ")}
+			}
+			leaf.extend(i)
+		}
+	}
+	return doc
+}
+
+// listMarkerWidth returns the width of the list marker starting a line, with the spaces
+// following it, if the line starts a list item. Within a paragraph, only non-empty items
+// of bullet lists or of ordered lists starting at 1 can start.
+func listMarkerWidth(line string, interrupting bool) (int, bool) {
+	var marker string
+	if match := bulletPattern.FindStringSubmatch(line); match != nil {
+		marker = match[1]
+	} else if match := orderedPattern.FindStringSubmatch(line); match != nil {
+		if start, _ := strconv.Atoi(match[1]); interrupting && start != 1 {
+			return 0, false
+		}
+		marker = match[1] + match[2]
+	} else {
+		return 0, false
+	}
+
+	rest := line[len(marker):]
+	if isBlank(rest) {
+		if interrupting {
+			return 0, false
+		}
+		return len(marker) + 1, true
+	}
+	spaces := leadingSpaces(rest)
+	if spaces > 4 {
+		// The content is an indented code block, after a single space
+		spaces = 1
+	}
+	return len(marker) + spaces, true
+}
+
+// closesFence reports whether a line closes a code block opened with the given fence.
+func closesFence(line string, fence string) bool {
+	if leadingSpaces(line) >= 4 {
+		return false
+	}
+	line = strings.TrimSpace(line)
+	return len(line) >= len(fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+// expandIndent replaces the tabs in the leading whitespace and list markers of a line with
+// spaces, up to the next multiple of four columns, so that indentation can be measured in columns.
+func expandIndent(line string) string {
+	var builder strings.Builder
+	column := 0
+	for i, c := range line {
+		switch {
+		case c == '\t':
+			spaces := 4 - column%4
+			builder.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+		case strings.ContainsRune(" -*+.)0123456789", c):
+			builder.WriteRune(c)
+			column++
+		default:
+			builder.WriteString(line[i:])
+			return builder.String()
+		}
+	}
+	return builder.String()
+}
+
+// leadingSpaces counts the spaces at the start of a line.
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isBlank reports whether a line holds only whitespace.
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
@@ -0,0 +1,166 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// entityPattern matches a decimal numeric character reference, used for the characters
+// of titles that cannot be written as they are.
+var entityPattern = regexp.MustCompile(`^&#(\d{1,7});`)
+
+// taskLinePattern matches a note line that would read as a task list item, up to its checkbox.
+// Its first group is the list marker and the second the backslashes already escaping the checkbox.
+var taskLinePattern = regexp.MustCompile(`^([ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+)(\\*)\[[ xX]\]`)
+
+// leadingCheckboxPattern matches a title starting like the status of a heading task.
+var leadingCheckboxPattern = regexp.MustCompile(`^\[[ xX]\]`)
+
+// closingHashesPattern matches the hashes ending a title, which would read as the closing
+// sequence of a heading.
+var closingHashesPattern = regexp.MustCompile(`(^|[ \t])#+$`)
+
+// idMarkerPattern matches the "gtasks:" or "gtasks-list:" of an ID marker ending a title.
+var idMarkerPattern = regexp.MustCompile(`gtasks(?:-list)?:\S+\s*-->$`)
+
+// escapeInline escapes a title so that it is read back unchanged from a task line or a heading.
+// Line breaks and surrounding whitespace are written as character references, backslashes before
+// punctuation or such references are doubled, and an ending that would read as a date or ID
+// marker, a leading checkbox or closing hashes are broken up by a backslash.
+func escapeInline(text string) string {
+	// asReference reports whether the character at i is written as a character reference
+	asReference := func(i int) bool {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		return r == '\n' || r == '\r' || (unicode.IsSpace(r) && (i == 0 || i+size == len(text)))
+	}
+
+	var builder strings.Builder
+	for i, r := range text {
+		switch {
+		case r == '\\' && i+1 < len(text) && (isASCIIPunct(text[i+1]) || asReference(i+1)):
+			// A backslash before a character reference would escape its "&"
+			builder.WriteString(`\\`)
+		case r == '&' && entityPattern.MatchString(text[i:]):
+			builder.WriteString(`\&`)
+		case asReference(i):
+			fmt.Fprintf(&builder, "&#%d;", r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	escaped := builder.String()
+
+	if leadingCheckboxPattern.MatchString(escaped) {
+		escaped = `\` + escaped
+	}
+	if _, due, completed := splitDates(escaped); due != nil || completed != nil {
+		match := datePattern.FindStringSubmatchIndex(escaped)
+		// Break the date after its year
+		escaped = escaped[:match[4]+4] + `\` + escaped[match[4]+4:]
+	} else if match := idMarkerPattern.FindStringIndex(escaped); match != nil && (taskIDPattern.MatchString(escaped) || listIDPattern.MatchString(escaped)) {
+		colon := match[0] + strings.Index(escaped[match[0]:], ":")
+		escaped = escaped[:colon] + `\` + escaped[colon:]
+	} else if match := closingHashesPattern.FindStringSubmatchIndex(escaped); match != nil {
+		escaped = escaped[:match[3]] + `\` + escaped[match[3]:]
+	}
+	return escaped
+}
+
+// unescapeInline reverses escapeInline: backslashes before punctuation are dropped and numeric
+// character references are decoded.
+func unescapeInline(text string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			i++
+			builder.WriteByte(text[i])
+		case text[i] == '&' && entityPattern.MatchString(text[i:]):
+			match := entityPattern.FindStringSubmatch(text[i:])
+			code, _ := strconv.Atoi(match[1])
+			builder.WriteRune(rune(code))
+			i += len(match[0]) - 1
+		default:
+			builder.WriteByte(text[i])
+		}
+	}
+	return builder.String()
+}
+
+// escapeNotes splits notes into the lines to write under a task, escaped so that they are read
+// back unchanged. A backslash is added before the first character of lines that would end or
+// change the task: opening fences without a closing one, setext heading underlines and blank
+// lines at the start or end of the notes, as well as lines already starting with a backslash.
+// Checkboxes of lines that would read as tasks get a backslash too.
+func escapeNotes(notes string) []string {
+	lines := strings.Split(notes, "\n")
+	fence := ""
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(rest)]
+		// Lines indented by two columns more than the notes cannot start a block
+		shallow := leadingSpaces(expandIndent(line)) <= 1
+
+		escape := false
+		switch {
+		case strings.HasPrefix(rest, `\`) && (len(rest) == 1 || isASCIIPunct(rest[1])):
+			escape = true
+		case fence != "":
+			if shallow && closesFence(rest, fence) {
+				fence = ""
+			}
+		case shallow && isFence(rest):
+			opening := fencePattern.FindStringSubmatch(rest)[1]
+			escape = true
+			for _, next := range lines[i+1:] {
+				if leadingSpaces(expandIndent(next)) <= 1 && closesFence(strings.TrimLeft(next, " \t"), opening) {
+					fence, escape = opening, false
+					break
+				}
+			}
+		case shallow && setextPattern.MatchString(rest):
+			escape = true
+		case rest == "" && (i == 0 || i == len(lines)-1):
+			escape = true
+		}
+
+		if match := taskLinePattern.FindStringSubmatchIndex(line); match != nil {
+			line = line[:match[4]] + `\` + line[match[4]:]
+			rest = strings.TrimLeft(line, " \t")
+		}
+		if escape {
+			line = indent + `\` + rest
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// unescapeNotes reverses escapeNotes on the lines of notes read under a task.
+func unescapeNotes(lines []string) string {
+	unescaped := make([]string, len(lines))
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(rest, `\`) && (len(rest) == 1 || isASCIIPunct(rest[1])) {
+			line = line[:len(line)-len(rest)] + rest[1:]
+		} else if match := taskLinePattern.FindStringSubmatchIndex(line); match != nil && match[5] > match[4] {
+			line = line[:match[4]] + line[match[4]+1:]
+		}
+		unescaped[i] = line
+	}
+	return strings.Join(unescaped, "\n")
+}
+
+// isFence reports whether a line opens a fenced code block.
+func isFence(line string) bool {
+	return fencePattern.MatchString(line) && !(line[0] == '`' && strings.Contains(strings.TrimLeft(line, "`"), "`"))
+}
+
+// isASCIIPunct reports whether a character can be escaped with a backslash in CommonMark.
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
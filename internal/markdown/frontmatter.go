@@ -0,0 +1,194 @@
+package markdown
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gtasks2md/internal/models"
+)
+
+// Front matter keys with a dedicated field on models.TaskList.
+const (
+	listIDKey   = "gtasks_list_id"
+	syncedAtKey = "synced_at"
+)
+
+// splitFrontMatter separates the YAML front matter delimited by "---" lines at the start
+// of a file from the rest of its lines. The lines between the delimiters are returned as
+// written; without front matter, the block is nil and all lines are returned.
+func splitFrontMatter(lines []string) ([]string, []string) {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t\r") != "---" {
+		return nil, lines
+	}
+
+	for i, line := range lines[1:] {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == "---" || trimmed == "..." {
+			return append([]string{}, lines[1:i+1]...), lines[i+2:]
+		}
+	}
+
+	// An unterminated block is not front matter
+	return nil, lines
+}
+
+// frontMatterFields reads the top-level scalar values of a front matter block, entry by entry,
+// so that an entry that is not valid YAML does not hide the others. Keys holding nested
+// blocks are skipped.
+func frontMatterFields(block []string) map[string]string {
+	if block == nil {
+		return nil
+	}
+
+	var entries [][]string
+	for _, line := range block {
+		trimmed := strings.TrimSpace(line)
+		if len(entries) > 0 && (trimmed == "" || strings.HasPrefix(trimmed, "#") || isFrontMatterContinuation(line)) {
+			entries[len(entries)-1] = append(entries[len(entries)-1], line)
+			continue
+		}
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			entries = append(entries, []string{line})
+		}
+	}
+
+	fields := make(map[string]string)
+	for _, entry := range entries {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.Join(entry, "\n")), &node); err != nil || len(node.Content) == 0 {
+			continue
+		}
+		mapping := node.Content[0]
+		if mapping.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			value := mapping.Content[i+1]
+			if value.Kind == yaml.AliasNode {
+				value = value.Alias
+			}
+			if value.Kind != yaml.ScalarNode {
+				continue
+			}
+			if value.ShortTag() == "!!null" {
+				fields[mapping.Content[i].Value] = ""
+			} else {
+				fields[mapping.Content[i].Value] = value.Value
+			}
+		}
+	}
+	return fields
+}
+
+// isFrontMatterContinuation reports whether a front matter line belongs to the value of the
+// key above it, as the items of a list or the lines of a nested mapping do.
+func isFrontMatterContinuation(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || line == "-" || strings.HasPrefix(line, "- ")
+}
+
+// applyFrontMatter stores a front matter block on a task list: the list ID and sync time in
+// their fields, the other top-level pairs as metadata and the lines themselves as written.
+func applyFrontMatter(list *models.TaskList, block []string) {
+	if block == nil {
+		return
+	}
+	list.FrontMatter = block
+	for key, value := range frontMatterFields(block) {
+		switch key {
+		case listIDKey:
+			if value != "" {
+				id := value
+				list.ID = &id
+			}
+		case syncedAtKey:
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				list.SyncedAt = &t
+			}
+		default:
+			if list.Metadata == nil {
+				list.Metadata = make(map[string]string)
+			}
+			list.Metadata[key] = value
+		}
+	}
+}
+
+// frontMatterLines renders the list ID, sync time and custom keys of a list as YAML front
+// matter. The front matter read from the file is kept as written, with only the list ID and
+// sync time replaced; without it, the keys of the list metadata are written. With noSync, the
+// list ID and sync time are left out. Lists with nothing to write get no front matter.
+func frontMatterLines(list *models.TaskList, noSync bool) []string {
+	block := list.FrontMatter
+	if block == nil {
+		keys := make([]string, 0, len(list.Metadata))
+		for key := range list.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			block = append(block, fmt.Sprintf("%s: %s", key, quoteYAML(list.Metadata[key])))
+		}
+	}
+
+	var listID, syncedAt string
+	if list.ID != nil && !noSync {
+		listID = quoteYAML(*list.ID)
+	}
+	if list.SyncedAt != nil && !noSync {
+		syncedAt = list.SyncedAt.UTC().Format(time.RFC3339)
+	}
+	// The sync time goes first so that the list ID, inserted before it, ends up on top
+	block = setFrontMatterKey(block, syncedAtKey, syncedAt)
+	block = setFrontMatterKey(block, listIDKey, listID)
+	if len(block) == 0 {
+		return nil
+	}
+
+	lines := append([]string{"---"}, block...)
+	return append(lines, "---")
+}
+
+// setFrontMatterKey replaces the value of a top-level key in a front matter block, dropping
+// the nested lines of its old value, or inserts the key at the top of the block. An empty
+// value removes the key. The other lines are left untouched.
+func setFrontMatterKey(block []string, key string, value string) []string {
+	var result []string
+	found := false
+	for i := 0; i < len(block); i++ {
+		name, _, ok := strings.Cut(block[i], ":")
+		if !ok || name != key {
+			result = append(result, block[i])
+			continue
+		}
+		for i+1 < len(block) && isFrontMatterContinuation(block[i+1]) {
+			i++
+		}
+		if value != "" && !found {
+			result = append(result, fmt.Sprintf("%s: %s", key, value))
+		}
+		found = true
+	}
+	if !found && value != "" {
+		result = append([]string{fmt.Sprintf("%s: %s", key, value)}, result...)
+	}
+	return result
+}
+
+// quoteYAML writes a value as a YAML scalar on a single line, quoting it unless it is safe to
+// write as a plain scalar.
+func quoteYAML(value string) string {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if strings.ContainsAny(value, "\n\r") {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return strconv.Quote(value)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
@@ -1,8 +1,13 @@
 package markdown
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"gtasks2md/internal/models"
 )
 
 func TestParserAndSerializer(t *testing.T) {
@@ -47,7 +52,7 @@ func TestParserAndSerializer(t *testing.T) {
 	if subtask1.Title != "Pay at checkout" || subtask1.Status != "completed" {
 		t.Errorf("Subtask 1 parsed incorrectly: %+v", subtask1)
 	}
-	if subtask1.Notes == nil || *subtask1.Notes != "    Use new credit card" {
+	if subtask1.Notes == nil || *subtask1.Notes != "Use new credit card" {
 		if subtask1.Notes == nil {
 			t.Errorf("Subtask 1 notes parsed incorrectly: nil")
 		} else {
@@ -100,7 +105,601 @@ func TestParserEmptyLinesAndTabs(t *testing.T) {
 		t.Fatalf("Expected 1 subtask, got %d", len(task1.Children))
 	}
 	subtask1 := task1.Children[0]
-	if subtask1.Notes == nil || *subtask1.Notes != "\tNote for subtask 1" {
+	if subtask1.Notes == nil || *subtask1.Notes != "Note for subtask 1" {
 		t.Errorf("Subtask 1 notes parsed incorrectly: %v", subtask1.Notes)
 	}
 }
+
+func TestTaskIDMarkers(t *testing.T) {
+	testContent := `# Work
+
+- [ ] Write report <!-- gtasks:abc123 -->
+    Due Friday
+    - [x] Collect data <!-- gtasks:def456 -->
+- [ ] New task
+`
+
+	taskList := NewParser(testContent).Parse()
+
+	if len(taskList.Tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(taskList.Tasks))
+	}
+
+	task1 := taskList.Tasks[0]
+	if task1.Title != "Write report" {
+		t.Errorf("Expected title 'Write report', got '%s'", task1.Title)
+	}
+	if task1.ID == nil || *task1.ID != "abc123" {
+		t.Errorf("Task 1 ID parsed incorrectly: %v", task1.ID)
+	}
+	if len(task1.Children) != 1 {
+		t.Fatalf("Expected 1 subtask, got %d", len(task1.Children))
+	}
+	subtask1 := task1.Children[0]
+	if subtask1.Title != "Collect data" || subtask1.ID == nil || *subtask1.ID != "def456" {
+		t.Errorf("Subtask 1 parsed incorrectly: %+v", subtask1)
+	}
+
+	task2 := taskList.Tasks[1]
+	if task2.ID != nil {
+		t.Errorf("Expected no ID for task 2, got '%s'", *task2.ID)
+	}
+
+	serializedContent := NewSerializer(taskList).Serialize()
+	if serializedContent != testContent {
+		t.Errorf("Serialized content does not match original.\nExpected:\n%s\nGot:\n%s", testContent, serializedContent)
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	input := `# Release
+
+- [ ] Prepare release
+    - [ ] Update docs
+        - [x] Changelog
+            Mention the new flag
+            - [ ] Link the issue
+        - [ ] API reference
+    - [ ] Tag
+- [ ] Announce
+`
+
+	list := NewParser(input).Parse()
+
+	if len(list.Tasks) != 2 {
+		t.Fatalf("Expected 2 top-level tasks, got %d", len(list.Tasks))
+	}
+	docs := list.Tasks[0].Children[0]
+	if docs.Title != "Update docs" || len(docs.Children) != 2 {
+		t.Fatalf("Expected 'Update docs' with 2 subtasks, got '%s' with %d", docs.Title, len(docs.Children))
+	}
+	changelog := docs.Children[0]
+	if changelog.Status != "completed" || changelog.Notes == nil || *changelog.Notes != "Mention the new flag" {
+		t.Errorf("Unexpected third-level task: %+v", changelog)
+	}
+	if len(changelog.Children) != 1 || changelog.Children[0].Title != "Link the issue" {
+		t.Errorf("Expected a fourth-level task 'Link the issue'")
+	}
+	if len(list.Tasks[0].Children) != 2 || list.Tasks[0].Children[1].Title != "Tag" {
+		t.Errorf("Expected 'Tag' to follow 'Update docs'")
+	}
+
+	if output := NewSerializer(list).Serialize(); output != input {
+		t.Errorf("Expected round trip to preserve the file, got:\n%s", output)
+	}
+}
+
+func TestDueDates(t *testing.T) {
+	testContent := `# Planning
+
+- [ ] Submit budget 📅 2026-10-20 <!-- gtasks:abc123 -->
+- [ ] Book venue due:2026-11-02
+- [ ] Fix date 📅 2026-13-40
+`
+
+	taskList := NewParser(testContent).Parse()
+
+	expected := []struct{ title, due string }{
+		{"Submit budget", "2026-10-20"},
+		{"Book venue", "2026-11-02"},
+		{"Fix date 📅 2026-13-40", ""},
+	}
+	for i, exp := range expected {
+		task := taskList.Tasks[i]
+		if task.Title != exp.title || task.DueDate() != exp.due {
+			t.Errorf("Task %d: expected '%s' due '%s', got '%s' due '%s'", i, exp.title, exp.due, task.Title, task.DueDate())
+		}
+	}
+
+	output := NewSerializer(taskList).Serialize()
+	expectedOutput := `# Planning
+
+- [ ] Submit budget 📅 2026-10-20 <!-- gtasks:abc123 -->
+- [ ] Book venue 📅 2026-11-02
+- [ ] Fix date 📅 2026-13-40
+`
+	if output != expectedOutput {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedOutput, output)
+	}
+}
+
+func TestCompletionDates(t *testing.T) {
+	testContent := `# Planning
+
+- [x] Submit budget 📅 2026-10-20 ✅ 2026-10-14 <!-- gtasks:abc123 -->
+- [x] Book venue ✅ 2026-10-15 due:2026-11-02
+- [ ] Send invites
+`
+
+	taskList := NewParser(testContent).Parse()
+
+	budget := taskList.Tasks[0]
+	if budget.Title != "Submit budget" || budget.DueDate() != "2026-10-20" {
+		t.Errorf("Unexpected task: '%s' due '%s'", budget.Title, budget.DueDate())
+	}
+	if budget.Completed == nil || budget.Completed.Format("2006-01-02") != "2026-10-14" {
+		t.Errorf("Expected completion date 2026-10-14, got %v", budget.Completed)
+	}
+	venue := taskList.Tasks[1]
+	if venue.Title != "Book venue" || venue.DueDate() != "2026-11-02" || venue.Completed == nil {
+		t.Errorf("Expected dates in any order to be read, got '%s' due '%s' completed %v", venue.Title, venue.DueDate(), venue.Completed)
+	}
+
+	withoutDates := NewSerializer(taskList).Serialize()
+	if strings.Contains(withoutDates, "✅") {
+		t.Errorf("Expected no completion dates by default, got:\n%s", withoutDates)
+	}
+
+	serializer := NewSerializer(taskList)
+	serializer.Options.CompletionDates = true
+	output := serializer.Serialize()
+	expectedOutput := `# Planning
+
+- [x] Submit budget 📅 2026-10-20 ✅ 2026-10-14 <!-- gtasks:abc123 -->
+- [x] Book venue 📅 2026-11-02 ✅ 2026-10-15
+- [ ] Send invites
+`
+	if output != expectedOutput {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedOutput, output)
+	}
+
+	// West of UTC, a task completed late in the evening is written and read back
+	// with its local date
+	local := time.Local
+	time.Local = time.FixedZone("UTC-7", -7*60*60)
+	defer func() { time.Local = local }()
+	completed := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+	lateList := &models.TaskList{Title: "Late", Tasks: []*models.Task{{Title: "Late", Status: "completed", Completed: &completed}}}
+	for i := 0; i < 2; i++ {
+		serializer := NewSerializer(lateList)
+		serializer.Options.CompletionDates = true
+		output := serializer.Serialize()
+		if !strings.Contains(output, "✅ 2026-10-14") {
+			t.Fatalf("Pass %d: expected the local completion date 2026-10-14, got:\n%s", i+1, output)
+		}
+		lateList = NewParser(output).Parse()
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	testContent := `---
+gtasks_list_id: MTIzNDU2Nzg5
+synced_at: 2026-10-14T09:30:00Z
+owner: 'Platform team'
+# Comments are kept
+review: "weekly: Mondays"
+tags:
+  - work
+  - q4
+aliases: [a, b]
+---
+# Groceries
+
+- [ ] Milk
+`
+
+	taskList := NewParser(testContent).Parse()
+
+	if taskList.ID == nil || *taskList.ID != "MTIzNDU2Nzg5" {
+		t.Errorf("Expected list ID 'MTIzNDU2Nzg5', got %v", taskList.ID)
+	}
+	if taskList.SyncedAt == nil || !taskList.SyncedAt.Equal(time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected sync time: %v", taskList.SyncedAt)
+	}
+	if taskList.Metadata["owner"] != "Platform team" || taskList.Metadata["review"] != "weekly: Mondays" {
+		t.Errorf("Unexpected custom keys: %v", taskList.Metadata)
+	}
+	if taskList.Title != "Groceries" || len(taskList.Tasks) != 1 {
+		t.Errorf("Expected the body to be parsed after the front matter, got '%s' with %d tasks", taskList.Title, len(taskList.Tasks))
+	}
+
+	if _, ok := taskList.Metadata["tags"]; ok {
+		t.Errorf("Expected nested values not to be read as custom keys, got %v", taskList.Metadata)
+	}
+
+	// Only the list ID and sync time are rewritten; the other lines are kept as written
+	synced := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	taskList.SyncedAt = &synced
+	output := NewSerializer(taskList).Serialize()
+	expectedOutput := `---
+gtasks_list_id: MTIzNDU2Nzg5
+synced_at: 2026-10-15T08:00:00Z
+owner: 'Platform team'
+# Comments are kept
+review: "weekly: Mondays"
+tags:
+  - work
+  - q4
+aliases: [a, b]
+---
+# Groceries
+
+- [ ] Milk
+`
+	if output != expectedOutput {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedOutput, output)
+	}
+
+	serializer := NewSerializer(taskList)
+	serializer.Options.NoFrontMatter = true
+	if output := serializer.Serialize(); strings.Contains(output, "gtasks_list_id") || strings.Contains(output, "synced_at") || !strings.HasPrefix(output, "---\nowner: 'Platform team'\n") {
+		t.Errorf("Expected only the custom front matter without sync keys, got:\n%s", output)
+	}
+	plain := &models.TaskList{Title: "Groceries", ID: taskList.ID, SyncedAt: &synced}
+	serializer = NewSerializer(plain)
+	serializer.Options.NoFrontMatter = true
+	if output := serializer.Serialize(); output != "# Groceries\n\n" {
+		t.Errorf("Expected no front matter at all, got:\n%s", output)
+	}
+
+	if list := NewParser("---\n# Not front matter\n- [ ] Task\n").Parse(); list.ID != nil || len(list.Tasks) != 1 {
+		t.Errorf("Expected an unterminated block not to be read as front matter")
+	}
+}
+
+func TestContentBlocks(t *testing.T) {
+	testContent := `# Release checklist
+
+Run through this list before **every** release.
+See [the runbook](https://example.com/runbook).
+
+| Owner | Area |
+|-------|------|
+| Ana   | QA   |
+
+- [ ] Freeze the branch
+    - [ ] Announce the freeze
+- [ ] Tag the release
+
+## After the release
+
+Keep an eye on the error rates.
+`
+
+	taskList := NewParser(testContent).Parse()
+
+	if len(taskList.Tasks) != 2 || len(taskList.Tasks[0].Children) != 1 {
+		t.Fatalf("Expected the tasks to be parsed around the content, got %d tasks", len(taskList.Tasks))
+	}
+	if len(taskList.Content) != 2 {
+		t.Fatalf("Expected 2 content blocks, got %d", len(taskList.Content))
+	}
+	if taskList.Content[0].After != nil || !strings.HasPrefix(taskList.Content[0].Text, "Run through") {
+		t.Errorf("Expected the first block to precede the tasks, got %+v", taskList.Content[0])
+	}
+	if taskList.Content[1].After != taskList.Tasks[1] || !strings.HasSuffix(taskList.Content[1].Text, "error rates.") {
+		t.Errorf("Expected the second block to follow 'Tag the release', got %+v", taskList.Content[1])
+	}
+
+	if output := NewSerializer(taskList).Serialize(); output != testContent {
+		t.Errorf("Expected round trip to preserve the file, got:\n%s", output)
+	}
+
+	// Content before the title stays at the start of the document
+	testContent = "---\nowner: Ana\n---\nText before title\n\n# Release checklist\n\nIntro\n\n- [ ] Freeze the branch\n"
+	taskList = NewParser(testContent).Parse()
+	if len(taskList.Content) != 2 || !taskList.Content[0].BeforeTitle || taskList.Content[1].BeforeTitle {
+		t.Fatalf("Expected only the first block to precede the title, got %+v", taskList.Content)
+	}
+	if output := NewSerializer(taskList).Serialize(); output != testContent {
+		t.Errorf("Expected round trip to keep the content before the title, got:\n%s", output)
+	}
+}
+
+func TestSections(t *testing.T) {
+	testContent := `---
+gtasks_sections: true
+---
+# Work
+
+## Today <!-- gtasks-list:TODAY -->
+
+- [ ] Answer emails <!-- gtasks:t1 -->
+
+## Backlog
+
+- [ ] Plan the offsite
+    Book a venue first
+`
+
+	parser := NewParser(testContent)
+	if !parser.HasSections() {
+		t.Fatalf("Expected the front matter to mark the content as holding sections")
+	}
+	lists := parser.ParseLists()
+	if len(lists) != 2 {
+		t.Fatalf("Expected 2 lists, got %d", len(lists))
+	}
+	if lists[0].Title != "Today" || lists[0].ID == nil || *lists[0].ID != "TODAY" || len(lists[0].Tasks) != 1 {
+		t.Errorf("Unexpected first list: %+v", lists[0])
+	}
+	if lists[1].Title != "Backlog" || lists[1].ID != nil || len(lists[1].Tasks) != 1 || lists[1].Tasks[0].Notes == nil {
+		t.Errorf("Unexpected second list: %+v", lists[1])
+	}
+
+	filePath := filepath.Join(t.TempDir(), "work.md")
+	if err := os.WriteFile(filePath, []byte(testContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backlogID := "BACKLOG"
+	backlog := &models.TaskList{
+		ID:    &backlogID,
+		Title: "Backlog",
+		Tasks: []*models.Task{{Title: "Hire a designer", Status: "needsAction"}},
+	}
+	if err := SaveToFile(backlog, filePath, SerializerOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `---
+gtasks_sections: true
+---
+# Work
+
+## Today <!-- gtasks-list:TODAY -->
+
+- [ ] Answer emails <!-- gtasks:t1 -->
+
+## Backlog <!-- gtasks-list:BACKLOG -->
+
+- [ ] Hire a designer
+`
+	if string(data) != expectedOutput {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedOutput, string(data))
+	}
+}
+
+func TestHeadingTasks(t *testing.T) {
+	testContent := `# Trip
+
+- [ ] Renew passport
+
+## Packing <!-- gtasks:p1 -->
+
+Check the weather first
+
+- [ ] Clothes
+    Pack for rain
+- [x] Charger
+
+### Documents
+
+- [ ] Tickets
+
+## [x] Booking
+
+- [x] Hotel
+`
+
+	parser := NewParser(testContent)
+	parser.Options = ParserOptions{HeadingTasks: true}
+	taskList := parser.Parse()
+
+	if len(taskList.Tasks) != 3 {
+		t.Fatalf("Expected 3 top-level tasks, got %d", len(taskList.Tasks))
+	}
+	packing := taskList.Tasks[1]
+	if packing.Title != "Packing" || packing.ID == nil || *packing.ID != "p1" || packing.Notes == nil || *packing.Notes != "Check the weather first" {
+		t.Errorf("Unexpected heading task: %+v", packing)
+	}
+	if len(packing.Children) != 3 || packing.Children[2].Title != "Documents" || packing.Children[0].Notes == nil {
+		t.Fatalf("Expected the checkboxes and sub-heading under 'Packing' to be its subtasks")
+	}
+	if len(packing.Children[2].Children) != 1 || packing.Children[2].Children[0].Title != "Tickets" {
+		t.Errorf("Expected 'Tickets' to be a subtask of 'Documents'")
+	}
+	if taskList.Tasks[2].Status != "completed" || taskList.Tasks[2].Title != "Booking" {
+		t.Errorf("Expected a completed 'Booking' heading task, got %+v", taskList.Tasks[2])
+	}
+
+	serializer := NewSerializer(taskList)
+	serializer.Options = SerializerOptions{HeadingTasks: true}
+	if output := serializer.Serialize(); output != testContent {
+		t.Errorf("Expected:\n%s\nGot:\n%s", testContent, output)
+	}
+
+	// Without the option, headings are kept as free-form content
+	if list := NewParser(testContent).Parse(); len(list.Tasks) != 5 {
+		t.Errorf("Expected headings to be ignored by default, got %d top-level tasks", len(list.Tasks))
+	}
+
+	// Notes are escaped under headings too, and those that paragraphs cannot hold keep their
+	// task, and the siblings before it, as checkboxes
+	notes := func(text string) *string { return &text }
+	for _, tt := range []struct {
+		note    string
+		heading bool
+	}{
+		{"---", true},
+		{"\\- escaped", true},
+		{"- item", false},
+		{"*", false},
+		{"    code", false},
+		{"  indented", false},
+		{"One\n\n\nTwo", false},
+	} {
+		note := tt.note
+		original := &models.TaskList{Title: "Trip", Tasks: []*models.Task{
+			{Title: "First", Status: "needsAction", Children: []*models.Task{{Title: "Sub", Status: "needsAction"}}},
+			{Title: "Second", Status: "needsAction", Notes: notes(note), Children: []*models.Task{{Title: "Sub", Status: "needsAction"}}},
+			{Title: "Third", Status: "needsAction", Notes: notes("Plain"), Children: []*models.Task{{Title: "Sub", Status: "needsAction"}}},
+		}}
+		serializer := NewSerializer(original)
+		serializer.Options = SerializerOptions{HeadingTasks: true}
+		output := serializer.Serialize()
+		if strings.Contains(output, "## Second") != tt.heading || !strings.Contains(output, "## Third\n") {
+			t.Errorf("Notes %q: expected 'Second' as a heading %v and 'Third' as a heading, got:\n%s", note, tt.heading, output)
+		}
+
+		parser := NewParser(output)
+		parser.Options = ParserOptions{HeadingTasks: true}
+		assertSameTasks(t, original.Tasks, parser.Parse().Tasks)
+	}
+}
+
+func TestCommonMarkLists(t *testing.T) {
+	testContent := "# Imported\n\n" +
+		"* [ ] Star bullet\n" +
+		"  continued on the next line\n" +
+		"lazy continuation\n" +
+		"  + [x] Plus bullet\n" +
+		"    ```\n" +
+		"    - [ ] Not a task\n" +
+		"    ```\n" +
+		"  - [Docs](https://example.com)\n" +
+		"1. [ ] Ordered\n" +
+		"   1) [X] Ordered subtask\n" +
+		"\n" +
+		"- Plain item\n" +
+		"* * *\n"
+
+	taskList := NewParser(testContent).Parse()
+
+	if len(taskList.Tasks) != 2 {
+		t.Fatalf("Expected 2 top-level tasks, got %d", len(taskList.Tasks))
+	}
+	star := taskList.Tasks[0]
+	if star.Title != "Star bullet" || star.Notes == nil || *star.Notes != "continued on the next line\nlazy continuation\n- [Docs](https://example.com)" {
+		t.Errorf("Unexpected task with lazy continuation lines: %+v", star)
+	}
+	if len(star.Children) != 1 {
+		t.Fatalf("Expected 1 subtask, got %d", len(star.Children))
+	}
+	plus := star.Children[0]
+	if plus.Status != "completed" || plus.Notes == nil || *plus.Notes != "```\n- [ ] Not a task\n```" {
+		t.Errorf("Expected fenced code to be kept in the notes, got %+v", plus)
+	}
+	ordered := taskList.Tasks[1]
+	if ordered.Title != "Ordered" || len(ordered.Children) != 1 || ordered.Children[0].Status != "completed" {
+		t.Errorf("Unexpected ordered list task: %+v", ordered)
+	}
+	if len(taskList.Content) != 1 || taskList.Content[0].Text != "- Plain item\n* * *" {
+		t.Errorf("Expected the plain list and the thematic break to be kept as content, got %+v", taskList.Content)
+	}
+
+	// The items of an ordered list follow each other, whatever their numbers
+	for _, items := range []string{
+		"1. [ ] One\n2. [x] Two\n3. [ ] Three\n",
+		"1) [ ] One\n2) [x] Two\n3) [ ] Three\n",
+		"9. [ ] One\n10. [x] Two\n11. [ ] Three\n",
+	} {
+		list := NewParser("# Ordered\n\n" + items).Parse()
+		if len(list.Tasks) != 3 || list.Tasks[1].Title != "Two" || list.Tasks[1].Status != "completed" || list.Tasks[0].Notes != nil {
+			t.Errorf("Expected 3 tasks from %q, got %d", items, len(list.Tasks))
+		}
+	}
+	// Within a paragraph, only a list starting at 1 can begin
+	if list := NewParser("# Ordered\n\n- [ ] One\n  Notes\n  2. [ ] Not a task\n").Parse(); len(list.Tasks[0].Children) != 0 {
+		t.Errorf("Expected an ordered item not starting at 1 to continue the notes")
+	}
+}
+
+func TestNotesIndentation(t *testing.T) {
+	for _, testContent := range []string{
+		"# Lists\n\n- [ ] Parent\n  Run:\n\n      x()\n        y()\n  - [ ] Child\n    Run:\n\n        x()\n          y()\n",
+		"# Lists\n\n1. [ ] Parent\n   Run:\n\n       x()\n         y()\n   1. [ ] Child\n      Run:\n\n          x()\n            y()\n",
+	} {
+		taskList := NewParser(testContent).Parse()
+		if len(taskList.Tasks) != 1 || len(taskList.Tasks[0].Children) != 1 {
+			t.Fatalf("Expected a task with a subtask from %q, got %+v", testContent, taskList.Tasks)
+		}
+		for _, task := range []*models.Task{taskList.Tasks[0], taskList.Tasks[0].Children[0]} {
+			if task.Notes == nil || *task.Notes != "Run:\n\n    x()\n      y()" {
+				t.Errorf("Expected the code in the notes of '%s' to keep its indentation, got %q", task.Title, stringValue(task.Notes))
+			}
+		}
+
+		// Written back with four columns per level, the notes read the same
+		output := NewSerializer(taskList).Serialize()
+		reparsed := NewParser(output).Parse()
+		if notes := reparsed.Tasks[0].Children[0].Notes; notes == nil || *notes != *taskList.Tasks[0].Children[0].Notes {
+			t.Errorf("Expected the notes to survive a rewrite, got %q from\n%s", stringValue(notes), output)
+		}
+	}
+}
+
+func TestEscapingRoundTrip(t *testing.T) {
+	notes := func(text string) *string { return &text }
+	original := &models.TaskList{
+		Title: "  # Tasks #",
+		Tasks: []*models.Task{
+			{Title: "# Not a heading", Status: "needsAction", Notes: notes("- [ ] Not a subtask\n1. [x] Nor this\n    leading spaces\n\\escaped\n")},
+			{Title: "[x] Looks done", Status: "needsAction", Notes: notes("```\nunclosed fence\n- [ ] inside")},
+			{Title: "Two\nlines ", Status: "completed", Notes: notes(""), Children: []*models.Task{
+				{Title: "Ends with a date 📅 2026-10-20", Status: "needsAction", Notes: notes("\nTitle\n---\n\n```go\n- [ ] fenced\n```\n\t")},
+				{Title: `C:\path\* <!-- gtasks:fake -->`, Status: "needsAction", Notes: notes("&#10; is kept\n\\- [ ] escaped once")},
+				{Title: "dir\\\nnext\\ ", Status: "needsAction", Notes: notes("- item")},
+				{Title: "Star", Status: "needsAction", Notes: notes("*")},
+			}},
+			{Title: "Indented", Status: "needsAction", Notes: notes("  indented"), Children: []*models.Task{
+				{Title: "Sub", Status: "needsAction"},
+			}},
+		},
+	}
+
+	for _, headingTasks := range []bool{false, true} {
+		serializer := NewSerializer(original)
+		serializer.Options.HeadingTasks = headingTasks
+		output := serializer.Serialize()
+
+		parser := NewParser(output)
+		parser.Options.HeadingTasks = headingTasks
+		parsed := parser.Parse()
+
+		if parsed.Title != original.Title {
+			t.Errorf("Expected list title %q, got %q", original.Title, parsed.Title)
+		}
+		assertSameTasks(t, original.Tasks, parsed.Tasks)
+		serializer = NewSerializer(parsed)
+		serializer.Options.HeadingTasks = headingTasks
+		if again := serializer.Serialize(); again != output {
+			t.Errorf("Expected serializing again to be idempotent with heading tasks %v, got:\n%s\nthen:\n%s", headingTasks, output, again)
+		}
+	}
+}
+
+// assertSameTasks compares the titles, statuses, notes and subtasks of two task trees.
+func assertSameTasks(t *testing.T, expected []*models.Task, actual []*models.Task) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d tasks, got %d", len(expected), len(actual))
+	}
+	for i, task := range expected {
+		got := actual[i]
+		if got.Title != task.Title || got.Status != task.Status || stringValue(got.Notes) != stringValue(task.Notes) || (got.Notes == nil) != (task.Notes == nil) {
+			t.Errorf("Expected %q (%s) with notes %q, got %q (%s) with notes %q", task.Title, task.Status, stringValue(task.Notes), got.Title, got.Status, stringValue(got.Notes))
+		}
+		assertSameTasks(t, task.Children, got.Children)
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
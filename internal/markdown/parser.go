@@ -4,12 +4,37 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"gtasks2md/internal/models"
 )
 
+// taskIDPattern matches the Google Task ID marker appended to task lines by the Serializer.
+var taskIDPattern = regexp.MustCompile(`\s*<!--\s*gtasks:(\S+)\s*-->\s*$`)
+
+// datePattern matches a date at the end of a task title: a due date written as "📅 2026-10-20"
+// or "due:2026-10-20", or a completion date written as "✅ 2026-10-14".
+var datePattern = regexp.MustCompile(`\s*(📅\s*|due:|✅\s*)(\d{4}-\d{2}-\d{2})\s*$`)
+
+// checkboxPattern matches the checkbox starting the first line of a task list item.
+var checkboxPattern = regexp.MustCompile(`^\[( |x|X)\][ \t]+(.*)$`)
+
+// headingStatusPattern matches the checkbox marking the status of a heading task.
+var headingStatusPattern = regexp.MustCompile(`^\[( |x|X)\] (.*)$`)
+
+// ParserOptions controls how Markdown is read.
+type ParserOptions struct {
+	// Sections reads files as holding one list per "## " section, even without front matter
+	// saying so. It only applies to LoadListsFromFile.
+	Sections bool
+	// HeadingTasks reads "##" to "######" headings as tasks, with the checkboxes and deeper
+	// headings under them as their subtasks and the paragraph right below them as their notes.
+	HeadingTasks bool
+}
+
 type Parser struct {
 	content string
+	Options ParserOptions
 }
 
 func NewParser(content string) *Parser {
@@ -17,96 +42,272 @@ func NewParser(content string) *Parser {
 }
 
 func (p *Parser) Parse() *models.TaskList {
-	lines := strings.Split(p.content, "\n")
+	frontMatter, lines := splitFrontMatter(strings.Split(p.content, "\n"))
 
 	listTitle := "Untitled List"
 	var tasks []*models.Task
 
-	var currentTask *models.Task
-	var currentSubtask *models.Task
+	// With heading tasks, headings holds the enclosing heading tasks, whose levels are kept in levels
+	var headings []*models.Task
+	var levels []int
+	attach := func(task *models.Task) {
+		if len(headings) == 0 {
+			tasks = append(tasks, task)
+		} else {
+			parent := headings[len(headings)-1]
+			parent.Children = append(parent.Children, task)
+		}
+	}
 
+	// Blocks that are neither the title, tasks nor notes are kept as free-form content
+	var content []*models.ContentBlock
+	first, last := -1, -1
 	titleSet := false
+	flushBlock := func() {
+		if first >= 0 {
+			contentBlock := &models.ContentBlock{Text: strings.Join(trimBlankLines(lines[first:last+1]), "\n")}
+			if len(tasks) > 0 {
+				contentBlock.After = tasks[len(tasks)-1]
+			} else if !titleSet {
+				contentBlock.BeforeTitle = true
+			}
+			content = append(content, contentBlock)
+		}
+		first, last = -1, -1
+	}
 
-	titlePattern := regexp.MustCompile(`^# (.*)`)
-	taskPattern := regexp.MustCompile(`^- \[( |x|X)\] (.*)`)
-	subtaskPattern := regexp.MustCompile(`^(    |\t)- \[( |x|X)\] (.*)`)
-	notePrefixPattern := regexp.MustCompile(`^(    |\t)(.*)`)
+	// headingNotes is the heading task whose notes are the paragraphs right below it
+	var headingNotes *models.Task
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
+	for _, b := range parseBlocks(lines).children {
+		text := strings.TrimSpace(strings.Join(b.text, " "))
 
-		if match := titlePattern.FindStringSubmatch(line); match != nil {
-			if !titleSet {
-				listTitle = strings.TrimSpace(match[1])
-				titleSet = true
-			}
-			continue
-		}
+		switch {
+		case b.kind == headingBlock && b.level == 1 && !titleSet:
+			flushBlock()
+			listTitle = unescapeInline(text)
+			titleSet = true
+			headingNotes = nil
+		case b.kind == headingBlock && b.level >= 2 && p.Options.HeadingTasks:
+			flushBlock()
 
-		if match := taskPattern.FindStringSubmatch(line); match != nil {
-			statusChar := strings.ToLower(match[1])
-			status := "needsAction"
-			if statusChar == "x" {
-				status = "completed"
+			// A heading closes the subtasks of the previous one and the headings at its level or below
+			for len(levels) > 0 && levels[len(levels)-1] >= b.level {
+				levels = levels[:len(levels)-1]
+				headings = headings[:len(headings)-1]
 			}
-			title := strings.TrimSpace(match[2])
 
-			currentTask = &models.Task{
-				Title:  title,
-				Status: status,
+			statusChar := " "
+			if status := headingStatusPattern.FindStringSubmatch(text); status != nil {
+				statusChar, text = status[1], status[2]
 			}
-			currentSubtask = nil
-			tasks = append(tasks, currentTask)
+			task := newTask(statusChar, text)
+			attach(task)
+			headings = append(headings, task)
+			levels = append(levels, b.level)
+			headingNotes = task
+		case b.kind == paragraphBlock && headingNotes != nil:
+			noteText := unescapeNotes(b.text)
+			if headingNotes.Notes != nil {
+				noteText = *headingNotes.Notes + "\n\n" + noteText
+			}
+			headingNotes.Notes = &noteText
+		case checkbox(b) != nil:
+			flushBlock()
+			attach(itemTask(b, lines, 0, 0))
+			headingNotes = nil
+		default:
+			if first < 0 {
+				first = b.first
+			}
+			last = b.last
+			headingNotes = nil
+		}
+	}
+	flushBlock()
+
+	list := &models.TaskList{
+		Title:   listTitle,
+		Tasks:   tasks,
+		Content: content,
+	}
+	applyFrontMatter(list, frontMatter)
+	return list
+}
+
+// checkbox returns the status character and the rest of the first line of a task list item,
+// or nil if the block is not one.
+func checkbox(b *block) []string {
+	if b.kind != listItemBlock || len(b.children) == 0 {
+		return nil
+	}
+	paragraph := b.children[0]
+	if paragraph.kind != paragraphBlock || paragraph.first != b.first {
+		return nil
+	}
+	match := checkboxPattern.FindStringSubmatch(paragraph.text[0])
+	if match == nil {
+		return nil
+	}
+	return match[1:]
+}
+
+// itemTask builds a task from a task list item at the given depth, whose container's content
+// starts at the given column. The task items nested in it are its subtasks, and its other lines
+// are its notes, dedented to the content column of the item. Notes written by the Serializer,
+// all indented by four columns per level, are dedented by that much instead.
+func itemTask(item *block, lines []string, depth int, indent int) *models.Task {
+	match := checkbox(item)
+	task := newTask(match[0], match[1])
+
+	var notes []string
+	next := item.first + 1
+	for _, child := range item.children {
+		if checkbox(child) == nil {
 			continue
 		}
+		notes = append(notes, lines[next:child.first]...)
+		task.Children = append(task.Children, itemTask(child, lines, depth+1, indent+item.width))
+		next = child.last + 1
+	}
+	notes = append(notes, lines[next:item.last+1]...)
 
-		if match := subtaskPattern.FindStringSubmatch(line); match != nil {
-			if currentTask == nil {
-				continue
-			}
+	notes = trimBlankLines(notes)
+	columns := indent + item.width
+	if serialized := 4 * (depth + 1); serialized > columns && indentedBy(notes, serialized) {
+		columns = serialized
+	}
+	for i, line := range notes {
+		notes[i] = dedent(line, columns)
+	}
+	if len(notes) > 0 {
+		noteText := unescapeNotes(notes)
+		task.Notes = &noteText
+	}
+	return task
+}
 
-			statusChar := strings.ToLower(match[2])
-			status := "needsAction"
-			if statusChar == "x" {
-				status = "completed"
-			}
-			title := strings.TrimSpace(match[3])
+// newTask builds a task from the status character of its checkbox and the rest of its line,
+// splitting off its ID marker and dates.
+func newTask(statusChar string, text string) *models.Task {
+	status := "needsAction"
+	if strings.ToLower(statusChar) == "x" {
+		status = "completed"
+	}
+	title, id := splitTaskID(strings.TrimSpace(text))
+	title, due, completed := splitDates(title)
+
+	return &models.Task{
+		ID:        id,
+		Title:     unescapeInline(title),
+		Status:    status,
+		Due:       due,
+		Completed: completed,
+	}
+}
 
-			currentSubtask = &models.Task{
-				Title:  title,
-				Status: status,
+// dedent removes up to the given number of columns of indentation from a line, a tab
+// counting up to the next multiple of four columns.
+func dedent(line string, columns int) string {
+	column := 0
+	for i, c := range line {
+		switch c {
+		case ' ':
+			column++
+		case '\t':
+			column += 4 - column%4
+		default:
+			return line[i:]
+		}
+		if column >= columns {
+			if column > columns {
+				return line[i:]
 			}
-			currentTask.Children = append(currentTask.Children, currentSubtask)
-			continue
+			return line[i+1:]
 		}
+	}
+	return ""
+}
 
-		if match := notePrefixPattern.FindStringSubmatch(line); match != nil {
-			rest := match[2]
-			if !strings.HasPrefix(rest, "- [") {
-				noteText := rest
-				targetTask := currentTask
-				if currentSubtask != nil {
-					targetTask = currentSubtask
-				}
-
-				if targetTask != nil {
-					if targetTask.Notes != nil {
-						newNotes := *targetTask.Notes + "\n" + noteText
-						targetTask.Notes = &newNotes
-					} else {
-						targetTask.Notes = &noteText
-					}
-				}
-				continue
+// indentedBy reports whether every non-blank line is indented by at least the given number
+// of columns, a tab counting up to the next multiple of four columns.
+func indentedBy(lines []string, columns int) bool {
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		column := 0
+		for _, c := range line {
+			if c == ' ' {
+				column++
+			} else if c == '\t' {
+				column += 4 - column%4
+			} else {
+				break
 			}
 		}
+		if column < columns {
+			return false
+		}
+	}
+	return true
+}
+
+// trimBlankLines removes the blank lines at the start and end of a block of lines.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitTaskID strips a trailing task ID marker from a task line and returns
+// the remaining title along with the ID, if any.
+func splitTaskID(text string) (string, *string) {
+	match := taskIDPattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		return text, nil
 	}
+	id := text[match[2]:match[3]]
+	return strings.TrimSpace(text[:match[0]]), &id
+}
+
+// splitDates strips the trailing due and completion dates from a task title, in any order,
+// and returns the remaining title along with the dates, if any. Invalid dates are left in
+// the title. Completion dates are read in local time, as the Serializer writes them.
+func splitDates(text string) (string, *time.Time, *time.Time) {
+	var due, completed *time.Time
+	for {
+		match := datePattern.FindStringSubmatchIndex(text)
+		if match == nil {
+			return text, due, completed
+		}
 
-	return &models.TaskList{
-		Title: listTitle,
-		Tasks: tasks,
+		marker := strings.TrimSpace(text[match[2]:match[3]])
+		date := text[match[4]:match[5]]
+		if marker == "✅" {
+			if completed != nil {
+				return text, due, completed
+			}
+			d, err := models.ParseCompletionDate(date)
+			if err != nil {
+				return text, due, completed
+			}
+			completed = d
+		} else {
+			if due != nil {
+				return text, due, completed
+			}
+			d, err := time.Parse(models.DateLayout, date)
+			if err != nil {
+				return text, due, completed
+			}
+			due = &d
+		}
+		text = strings.TrimSpace(text[:match[0]])
 	}
 }
 
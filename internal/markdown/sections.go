@@ -0,0 +1,195 @@
+package markdown
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gtasks2md/internal/models"
+)
+
+// sectionsKey is the front matter key marking a file that holds one task list per "## " section.
+const sectionsKey = "gtasks_sections"
+
+// sectionPattern matches a section heading, with the Google list ID marker written by the Serializer.
+var sectionPattern = regexp.MustCompile(`^## (.*)$`)
+
+// listIDPattern matches the Google list ID marker appended to section headings.
+var listIDPattern = regexp.MustCompile(`\s*<!--\s*gtasks-list:(\S+)\s*-->\s*$`)
+
+// HasSections reports whether the front matter of the content marks it as holding several lists.
+func (p *Parser) HasSections() bool {
+	block, _ := splitFrontMatter(strings.Split(p.content, "\n"))
+	return frontMatterFields(block)[sectionsKey] == "true"
+}
+
+// ParseLists parses content holding one task list per "## " section. The title and ID of each
+// list come from its heading; anything before the first section is ignored.
+func (p *Parser) ParseLists() []*models.TaskList {
+	_, lists := p.parseSections()
+	return lists
+}
+
+// parseSections splits content into the lines preceding the first section, front matter
+// included, and the task lists of the sections.
+func (p *Parser) parseSections() ([]string, []*models.TaskList) {
+	lines := strings.Split(p.content, "\n")
+	_, body := splitFrontMatter(lines)
+	start := len(lines) - len(body)
+
+	// Sections start at the "## " headings of the document, not at lines in code blocks or lists
+	headings := make(map[int]bool)
+	for _, b := range parseBlocks(body).children {
+		if b.kind == headingBlock && b.level == 2 {
+			headings[start+b.first] = true
+		}
+	}
+
+	var preamble []string
+	var lists []*models.TaskList
+	var title string
+	var section []string
+	flush := func() {
+		if section == nil {
+			return
+		}
+		heading, id := splitListID(title)
+		// Each section is parsed as a file of its own, headed by the section title
+		parser := NewParser("# " + heading + "\n" + strings.Join(section, "\n"))
+		parser.Options = p.Options
+		list := parser.Parse()
+		list.Title = unescapeInline(heading)
+		list.ID = id
+		lists = append(lists, list)
+	}
+
+	for i, line := range lines {
+		if headings[i] {
+			if match := sectionPattern.FindStringSubmatch(line); match != nil {
+				flush()
+				title = strings.TrimSpace(match[1])
+				section = []string{}
+				continue
+			}
+		}
+		if section == nil {
+			preamble = append(preamble, line)
+		} else {
+			section = append(section, line)
+		}
+	}
+	flush()
+
+	return preamble, lists
+}
+
+// splitListID strips a trailing list ID marker from a section heading and returns the
+// remaining title along with the ID, if any.
+func splitListID(text string) (string, *string) {
+	match := listIDPattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		return text, nil
+	}
+	id := text[match[2]:match[3]]
+	return strings.TrimSpace(text[:match[0]]), &id
+}
+
+// serializeSection renders a task list as a "## " section headed by its title and ID marker.
+func (s *Serializer) serializeSection() string {
+	section := *s.tasklist
+	section.ID = nil
+	section.SyncedAt = nil
+	section.Metadata = nil
+	section.FrontMatter = nil
+
+	serializer := &Serializer{tasklist: &section, Options: s.Options, headingLevel: 3}
+	lines := strings.Split(serializer.Serialize(), "\n")
+
+	heading := "## " + escapeInline(s.tasklist.Title)
+	if s.tasklist.ID != nil && *s.tasklist.ID != "" {
+		heading += fmt.Sprintf(" <!-- gtasks-list:%s -->", *s.tasklist.ID)
+	}
+	lines[0] = heading
+	return strings.Join(lines, "\n")
+}
+
+// SerializeLists renders several task lists as "## " sections of a single file, after the
+// given preamble lines. Front matter marking the file as holding sections is added if missing,
+// unless options.NoFrontMatter is set.
+func SerializeLists(preamble []string, lists []*models.TaskList, options SerializerOptions) string {
+	block, _ := splitFrontMatter(preamble)
+	fields := frontMatterFields(block)
+	switch {
+	case options.NoFrontMatter:
+	case block == nil:
+		preamble = append([]string{"---", sectionsKey + ": true", "---"}, preamble...)
+	case fields[sectionsKey] != "true":
+		preamble = append([]string{preamble[0], sectionsKey + ": true"}, preamble[1:]...)
+	}
+
+	// Keep a single blank line between the preamble and the first section
+	for len(preamble) > 0 && strings.TrimSpace(preamble[len(preamble)-1]) == "" {
+		preamble = preamble[:len(preamble)-1]
+	}
+	content := strings.Join(preamble, "\n") + "\n"
+
+	for _, list := range lists {
+		serializer := NewSerializer(list)
+		serializer.Options = options
+		content += "\n" + serializer.serializeSection()
+	}
+	return content
+}
+
+// LoadListsFromFile loads the task lists of a file: one per "## " section with options.Sections
+// or when the front matter of the file says so, and otherwise the single list of the file.
+// It also reports whether the file was read as sections.
+func LoadListsFromFile(filePath string, options ParserOptions) ([]*models.TaskList, bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+	parser := NewParser(string(data))
+	parser.Options = options
+	if options.Sections || parser.HasSections() {
+		return parser.ParseLists(), true, nil
+	}
+	return []*models.TaskList{parser.Parse()}, false, nil
+}
+
+// saveSection writes a task list into its section of a sections file, replacing the section
+// with the same list ID or, failing that, the same title. New lists are appended.
+func saveSection(tasklist *models.TaskList, filePath string, options SerializerOptions) error {
+	var preamble []string
+	var lists []*models.TaskList
+	if data, err := os.ReadFile(filePath); err == nil {
+		parser := NewParser(string(data))
+		parser.Options = options.ParserOptions()
+		preamble, lists = parser.parseSections()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	replaced := false
+	for i, list := range lists {
+		if list.ID != nil && tasklist.ID != nil && *list.ID == *tasklist.ID {
+			lists[i] = tasklist
+			replaced = true
+			break
+		}
+	}
+	for i, list := range lists {
+		if !replaced && list.Title == tasklist.Title {
+			lists[i] = tasklist
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lists = append(lists, tasklist)
+	}
+
+	content := SerializeLists(preamble, lists, options)
+	return os.WriteFile(filePath, []byte(content), 0644)
+}
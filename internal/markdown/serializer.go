@@ -8,8 +8,31 @@ import (
 	"gtasks2md/internal/models"
 )
 
+// SerializerOptions controls the optional parts of the Markdown output.
+type SerializerOptions struct {
+	// CompletionDates appends the completion date of completed tasks, as in "✅ 2026-10-14".
+	CompletionDates bool
+	// Sections writes lists as "## " sections of a file holding several lists.
+	Sections bool
+	// HeadingTasks writes tasks with subtasks as headings, and their subtasks as the
+	// checkboxes and deeper headings under them.
+	HeadingTasks bool
+	// NoFrontMatter leaves the list ID and sync time out of the front matter, and the
+	// front matter out entirely when it holds nothing else.
+	NoFrontMatter bool
+}
+
+// ParserOptions returns the options reading back what is written with these options.
+func (o SerializerOptions) ParserOptions() ParserOptions {
+	return ParserOptions{Sections: o.Sections, HeadingTasks: o.HeadingTasks}
+}
+
 type Serializer struct {
 	tasklist *models.TaskList
+	Options  SerializerOptions
+
+	// headingLevel is the heading level of top-level heading tasks, 2 when unset.
+	headingLevel int
 }
 
 func NewSerializer(tasklist *models.TaskList) *Serializer {
@@ -17,43 +40,190 @@ func NewSerializer(tasklist *models.TaskList) *Serializer {
 }
 
 func (s *Serializer) Serialize() string {
-	var lines []string
-	lines = append(lines, fmt.Sprintf("# %s", s.tasklist.Title))
+	// Free-form content from before the title stays at the start of the document
+	lines := frontMatterLines(s.tasklist, s.Options.NoFrontMatter)
+	for _, block := range s.tasklist.Content {
+		if block.BeforeTitle {
+			lines = append(lines, block.Text, "")
+		}
+	}
+	lines = append(lines, fmt.Sprintf("# %s", escapeInline(s.tasklist.Title)))
 	lines = append(lines, "")
 
+	// Free-form content goes back after the task it followed, separated by blank lines.
+	// Blocks whose task is no longer in the list go at the end.
+	topLevel := make(map[*models.Task]bool)
 	for _, task := range s.tasklist.Tasks {
-		statusChar := " "
-		if task.Status == "completed" {
-			statusChar = "x"
+		topLevel[task] = true
+	}
+	var leading, trailing []*models.ContentBlock
+	blocksAfter := make(map[*models.Task][]*models.ContentBlock)
+	for _, block := range s.tasklist.Content {
+		switch {
+		case block.BeforeTitle:
+		case block.After == nil:
+			leading = append(leading, block)
+		case topLevel[block.After]:
+			blocksAfter[block.After] = append(blocksAfter[block.After], block)
+		default:
+			trailing = append(trailing, block)
 		}
-		lines = append(lines, fmt.Sprintf("- [%s] %s", statusChar, task.Title))
+	}
 
-		if task.Notes != nil && *task.Notes != "" {
-			for _, noteLine := range strings.Split(*task.Notes, "\n") {
-				lines = append(lines, fmt.Sprintf("    %s", noteLine))
+	afterBlock := false
+	appendBlocks := func(blocks []*models.ContentBlock) {
+		for _, block := range blocks {
+			if lines[len(lines)-1] != "" {
+				lines = append(lines, "")
 			}
+			lines = append(lines, block.Text)
+			afterBlock = true
 		}
+	}
 
-		for _, subtask := range task.Children {
-			subStatusChar := " "
-			if subtask.Status == "completed" {
-				subStatusChar = "x"
-			}
-			lines = append(lines, fmt.Sprintf("    - [%s] %s", subStatusChar, subtask.Title))
+	level := s.headingLevel
+	if level == 0 {
+		level = 2
+	}
+	start := s.headingStart(s.tasklist.Tasks, level)
 
-			if subtask.Notes != nil && *subtask.Notes != "" {
-				for _, noteLine := range strings.Split(*subtask.Notes, "\n") {
-					lines = append(lines, fmt.Sprintf("    %s", noteLine))
-				}
-			}
+	appendBlocks(leading)
+	for i, task := range s.tasklist.Tasks {
+		if afterBlock {
+			lines = append(lines, "")
+			afterBlock = false
 		}
+		if i >= start {
+			lines = s.appendHeading(lines, task, level)
+		} else {
+			lines = s.appendTask(lines, task, 0)
+		}
+		appendBlocks(blocksAfter[task])
 	}
+	appendBlocks(trailing)
 
 	return strings.Join(lines, "\n") + "\n"
 }
 
-func SaveToFile(tasklist *models.TaskList, filePath string) error {
+// appendTask writes a task, its notes and its subtasks, indented by depth levels.
+// Notes are indented one level more than the task.
+func (s *Serializer) appendTask(lines []string, task *models.Task, depth int) []string {
+	statusChar := " "
+	if task.Status == "completed" {
+		statusChar = "x"
+	}
+	lines = append(lines, fmt.Sprintf("%s- [%s] %s", strings.Repeat("    ", depth), statusChar, s.taskText(task)))
+
+	if task.Notes != nil {
+		noteIndent := strings.Repeat("    ", depth+1)
+		for _, noteLine := range escapeNotes(*task.Notes) {
+			if noteLine != "" {
+				noteLine = noteIndent + noteLine
+			}
+			lines = append(lines, noteLine)
+		}
+	}
+
+	for _, subtask := range task.Children {
+		lines = s.appendTask(lines, subtask, depth+1)
+	}
+	return lines
+}
+
+// headingStart returns the index of the first of the sibling tasks written as headings of the
+// given level: with Options.HeadingTasks, the first one with subtasks. Checkboxes following a
+// heading would become its subtasks, so the siblings after it are all written as headings, and
+// the run only starts after the last sibling whose notes cannot be written under a heading.
+func (s *Serializer) headingStart(tasks []*models.Task, level int) int {
+	start := len(tasks)
+	if !s.Options.HeadingTasks || level > 6 {
+		return start
+	}
+	for i := len(tasks) - 1; i >= 0 && fitsHeading(tasks[i]); i-- {
+		if len(tasks[i].Children) > 0 {
+			start = i
+		}
+	}
+	return start
+}
+
+// fitsHeading reports whether the notes of a task are read back unchanged from the paragraphs
+// below a heading. Notes holding other blocks, such as lists, thematic breaks or indented code,
+// or whose line indentation or blank lines paragraphs do not keep, only fit under a checkbox.
+func fitsHeading(task *models.Task) bool {
+	if task.Notes == nil {
+		return true
+	}
+	var paragraphs []string
+	for _, b := range parseBlocks(escapeNotes(*task.Notes)).children {
+		if b.kind != paragraphBlock {
+			return false
+		}
+		paragraphs = append(paragraphs, unescapeNotes(b.text))
+	}
+	return len(paragraphs) > 0 && strings.Join(paragraphs, "\n\n") == *task.Notes
+}
+
+// appendHeading writes a task as a heading of the given level, followed by its notes as a
+// paragraph and its subtasks as checkboxes and deeper headings.
+func (s *Serializer) appendHeading(lines []string, task *models.Task, level int) []string {
+	if lines[len(lines)-1] != "" {
+		lines = append(lines, "")
+	}
+	statusText := ""
+	if task.Status == "completed" {
+		statusText = "[x] "
+	}
+	lines = append(lines, fmt.Sprintf("%s %s%s", strings.Repeat("#", level), statusText, s.taskText(task)))
+
+	if task.Notes != nil {
+		lines = append(lines, "")
+		lines = append(lines, escapeNotes(*task.Notes)...)
+	}
+
+	start := s.headingStart(task.Children, level+1)
+	for i, subtask := range task.Children {
+		if i >= start {
+			lines = s.appendHeading(lines, subtask, level+1)
+			continue
+		}
+		if i == 0 {
+			lines = append(lines, "")
+		}
+		lines = s.appendTask(lines, subtask, 0)
+	}
+	return lines
+}
+
+// taskText returns the task title followed by its due date, its completion date and its
+// ID marker, when set. Completion dates are only written with Options.CompletionDates.
+func (s *Serializer) taskText(task *models.Task) string {
+	text := escapeInline(task.Title)
+	if task.Due != nil {
+		text += " 📅 " + task.DueDate()
+	}
+	if s.Options.CompletionDates && task.Status == "completed" && task.Completed != nil {
+		text += " ✅ " + task.CompletionDate()
+	}
+	if task.ID == nil || *task.ID == "" {
+		return text
+	}
+	return fmt.Sprintf("%s <!-- gtasks:%s -->", text, *task.ID)
+}
+
+// SaveToFile writes a task list to filePath. In a file holding several lists, or with
+// Options.Sections, only the section of the list is rewritten.
+func SaveToFile(tasklist *models.TaskList, filePath string, options SerializerOptions) error {
+	sections := options.Sections
+	if data, err := os.ReadFile(filePath); err == nil && NewParser(string(data)).HasSections() {
+		sections = true
+	}
+	if sections {
+		return saveSection(tasklist, filePath, options)
+	}
+
 	serializer := NewSerializer(tasklist)
+	serializer.Options = options
 	content := serializer.Serialize()
 	return os.WriteFile(filePath, []byte(content), 0644)
 }
\ No newline at end of file
@@ -1,16 +1,107 @@
 package models
 
+import "time"
+
+// DateLayout is the layout of dates in Markdown files and in the sync state.
+const DateLayout = "2006-01-02"
+
 type Task struct {
+	ID        *string
+	Title     string
+	Status    string // "needsAction" or "completed"
+	Notes     *string
+	Due       *time.Time // Due date; Google Tasks keeps only the date, at midnight UTC
+	Parent    *string
+	Completed *time.Time // Completion time, set on completed tasks
+	Updated   *time.Time // Last modification time reported by Google Tasks
+	Children  []*Task
+}
+
+type TaskList struct {
 	ID       *string
 	Title    string
-	Status   string // "needsAction" or "completed"
-	Notes    *string
-	Parent   *string
-	Children []*Task
+	Tasks    []*Task
+	SyncedAt *time.Time        // Last sync time recorded in the Markdown file
+	Metadata map[string]string // Custom front matter keys of the Markdown file
+	// FrontMatter holds the front matter lines of the Markdown file as written, nil without front matter
+	FrontMatter []string
+	Content     []*ContentBlock // Free-form Markdown kept around the tasks
 }
 
-type TaskList struct {
-	ID    *string
-	Title string
-	Tasks []*Task
-}
\ No newline at end of file
+// ContentBlock is free-form Markdown kept verbatim from a file, such as a paragraph, a heading or a table.
+type ContentBlock struct {
+	Text string
+	// After is the top-level task the block follows, nil for blocks before the first task.
+	After *Task
+	// BeforeTitle marks blocks that precede the list title, at the start of the document.
+	BeforeTitle bool
+}
+
+// Diff lists the fields whose values differ between two versions of a task.
+// The parent is not compared, since it is expressed by the position of a task
+// in the hierarchy rather than by the task itself.
+func (t *Task) Diff(other *Task) []string {
+	var changes []string
+	if t.Title != other.Title {
+		changes = append(changes, "title")
+	}
+	if t.Status != other.Status {
+		changes = append(changes, "status")
+	}
+	if StringValue(t.Notes) != StringValue(other.Notes) {
+		changes = append(changes, "notes")
+	}
+	if t.DueDate() != other.DueDate() {
+		changes = append(changes, "due")
+	}
+	return changes
+}
+
+// DueDate returns the due date of the task formatted as YYYY-MM-DD, or an empty string.
+func (t *Task) DueDate() string {
+	if t.Due == nil {
+		return ""
+	}
+	return t.Due.Format(DateLayout)
+}
+
+// CompletionDate returns the local date on which the task was completed, or an empty string.
+func (t *Task) CompletionDate() string {
+	if t.Completed == nil {
+		return ""
+	}
+	return t.Completed.Local().Format(DateLayout)
+}
+
+// ParseCompletionDate parses a YYYY-MM-DD completion date, as returned by CompletionDate, as
+// local midnight so that it is written back as the same date. An empty string yields nil.
+func ParseCompletionDate(date string) (*time.Time, error) {
+	if date == "" {
+		return nil, nil
+	}
+	d, err := time.ParseInLocation(DateLayout, date, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ParseDate parses a YYYY-MM-DD date, as returned by DueDate. An empty string yields nil.
+func ParseDate(date string) (*time.Time, error) {
+	if date == "" {
+		return nil, nil
+	}
+	d, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// StringValue returns the string s points to, or an empty string for nil.
+func StringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gtasks2md/internal/api"
+	"gtasks2md/internal/models"
+)
+
+// batchThreshold is the number of operations above which a plan is applied through batch requests.
+const batchThreshold = 10
+
+// batchedOperation is an operation queued in a wave, with the sibling it is actually placed after.
+type batchedOperation struct {
+	op       *Operation
+	previous *models.Task
+}
+
+// planWaves groups operations into waves that can each be sent as one batch. An operation
+// starts a new wave when it needs the ID of a task created in the current wave, or when it
+// deletes a task whose subtasks are still being moved or deleted in it.
+//
+// A run of new siblings is created in reverse order after the sibling preceding the run,
+// so that it does not need the IDs of its own tasks. This relies on the calls of a batch
+// being applied in order, which executeBatched verifies afterwards.
+func planWaves(ops []*Operation) [][]*batchedOperation {
+	var waves [][]*batchedOperation
+	var wave []*batchedOperation
+	created := make(map[*models.Task]int)
+	anchors := make(map[*models.Task]*models.Task)
+	touched := make(map[*models.Task]bool)
+
+	flush := func() {
+		if len(wave) > 0 {
+			waves = append(waves, wave)
+		}
+		wave = nil
+		created = make(map[*models.Task]int)
+		anchors = make(map[*models.Task]*models.Task)
+		touched = make(map[*models.Task]bool)
+	}
+
+	for _, op := range ops {
+		_, parentPending := created[op.Parent]
+		_, previousPending := created[op.Previous]
+
+		switch op.Kind {
+		case OperationCreate:
+			if op.Parent != nil && parentPending {
+				flush()
+				previousPending = false
+			}
+			if op.Previous != nil && previousPending {
+				// Insert the task before its predecessor, after the same anchor
+				i := created[op.Previous]
+				entry := &batchedOperation{op: op, previous: anchors[op.Previous]}
+				wave = append(wave[:i], append([]*batchedOperation{entry}, wave[i:]...)...)
+				for task, index := range created {
+					if index >= i {
+						created[task] = index + 1
+					}
+				}
+				created[op.Task] = i
+				anchors[op.Task] = anchors[op.Previous]
+				continue
+			}
+			created[op.Task] = len(wave)
+			anchors[op.Task] = op.Previous
+		case OperationMove:
+			if (op.Parent != nil && parentPending) || (op.Previous != nil && previousPending) {
+				flush()
+			}
+			touched[op.Remote] = true
+		case OperationDelete:
+			for _, child := range op.Remote.Children {
+				if touched[child] {
+					flush()
+					break
+				}
+			}
+			touched[op.Remote] = true
+		}
+		wave = append(wave, &batchedOperation{op: op, previous: op.Previous})
+	}
+	flush()
+	return waves
+}
+
+// executeBatched applies the operations of a plan through batch requests, one wave at a time.
+// Since Google Tasks does not guarantee the order in which the calls of a batch are applied,
+// the remote list is read back afterwards and any task out of place is moved again.
+func executeBatched(plan *Plan, remoteListID string, client *api.GoogleTasksClient, trash *Trash) error {
+	for _, wave := range planWaves(plan.Operations) {
+		batch := client.NewBatch()
+		for _, entry := range wave {
+			op := entry.op
+			switch op.Kind {
+			case OperationDelete:
+				batch.DeleteTask(remoteListID, op.Remote)
+			case OperationUpdate:
+				op.Remote.Title = op.Task.Title
+				op.Remote.Status = op.Task.Status
+				op.Remote.Notes = op.Task.Notes
+				op.Remote.Due = op.Task.Due
+				batch.UpdateTask(remoteListID, op.Remote)
+			case OperationCreate:
+				batch.CreateTask(remoteListID, op.Task, taskID(op.Parent), taskID(entry.previous))
+			case OperationMove:
+				batch.MoveTask(remoteListID, op.Remote, taskID(op.Parent), taskID(entry.previous))
+			}
+		}
+
+		var failures []string
+		for i, result := range batch.Do() {
+			op := wave[i].op
+			if op.Kind == OperationDelete {
+				if result.Err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to delete task '%s': %v\n", op.Remote.Title, result.Err)
+				} else if trash != nil {
+					trash.Add(remoteListID, plan.ListName, op.Remote)
+				}
+				continue
+			}
+			if result.Err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", op, result.Err))
+			}
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("%d operations failed:\n  %s", len(failures), strings.Join(failures, "\n  "))
+		}
+	}
+
+	remoteTasks, err := client.GetTasks(remoteListID)
+	if err != nil {
+		return err
+	}
+	for _, op := range PlanTasklist(plan.local, remoteTasks, DeleteNever, plan.caps).Operations {
+		if op.Kind != OperationMove {
+			continue
+		}
+		if _, err := client.MoveTask(remoteListID, *op.Remote.ID, taskID(op.Parent), taskID(op.Previous)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/markdown"
+	"gtasks2md/internal/models"
+)
+
+// formatOf returns the file format selected by opts, Markdown when none is.
+func formatOf(opts SyncOptions) format.Format {
+	if opts.Format == nil {
+		return format.Markdown{Options: opts.Markdown}
+	}
+	return opts.Format
+}
+
+// isMarkdown reports whether opts select Markdown, whose files also keep sections, front matter
+// and free-form content around the tasks.
+func isMarkdown(opts SyncOptions) bool {
+	_, ok := formatOf(opts).(format.Markdown)
+	return ok
+}
+
+// loadFile reads the lists of a file in the format of opts, and reports whether the file holds
+// one list per section. Only Markdown files can hold several lists.
+func loadFile(filePath string, opts SyncOptions) ([]*models.TaskList, bool, error) {
+	if isMarkdown(opts) {
+		return markdown.LoadListsFromFile(filePath, opts.Markdown.ParserOptions())
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+	f := formatOf(opts)
+	tasklist, err := f.Decode(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: invalid %s: %v", filePath, f.Name(), err)
+	}
+	return []*models.TaskList{tasklist}, false, nil
+}
+
+// saveFile writes a list to a file in the format of opts, warning about the parts of the list
+// the format cannot hold.
+func saveFile(tasklist *models.TaskList, filePath string, opts SyncOptions) error {
+	if isMarkdown(opts) {
+		return markdown.SaveToFile(tasklist, filePath, opts.Markdown)
+	}
+
+	f := formatOf(opts)
+	if dropped := format.Unsupported(tasklist, f.Capabilities()); len(dropped) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s: the %s format does not keep %s\n", filePath, f.Name(), strings.Join(dropped, ", "))
+	}
+	data, err := f.Encode(tasklist)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
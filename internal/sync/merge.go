@@ -0,0 +1,487 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gtasks2md/internal/api"
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/models"
+)
+
+// ConflictStrategy decides which side wins when a field was changed both locally and remotely.
+type ConflictStrategy string
+
+const (
+	ConflictLocalWins  ConflictStrategy = "local-wins"
+	ConflictRemoteWins ConflictStrategy = "remote-wins"
+	ConflictNewestWins ConflictStrategy = "newest-wins"
+	ConflictMark       ConflictStrategy = "mark"
+)
+
+// ParseConflictStrategy validates a conflict strategy name.
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(name); strategy {
+	case ConflictLocalWins, ConflictRemoteWins, ConflictNewestWins, ConflictMark:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy '%s' (expected local-wins, remote-wins, newest-wins or mark)", name)
+}
+
+// ChangeCounts counts the task changes applied in one direction.
+type ChangeCounts struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+func (c ChangeCounts) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d deleted", c.Created, c.Updated, c.Deleted)
+}
+
+// MergeSummary describes what a merge moved in each direction.
+type MergeSummary struct {
+	Pulled    ChangeCounts // Remote changes applied to the local file
+	Pushed    ChangeCounts // Local changes applied to Google Tasks
+	Conflicts int
+}
+
+// Add accumulates another summary into s.
+func (s *MergeSummary) Add(other *MergeSummary) {
+	s.Pulled.Created += other.Pulled.Created
+	s.Pulled.Updated += other.Pulled.Updated
+	s.Pulled.Deleted += other.Pulled.Deleted
+	s.Pushed.Created += other.Pushed.Created
+	s.Pushed.Updated += other.Pushed.Updated
+	s.Pushed.Deleted += other.Pushed.Deleted
+	s.Conflicts += other.Conflicts
+}
+
+func (s *MergeSummary) String() string {
+	return fmt.Sprintf("pulled %s; pushed %s; %d conflicts", s.Pulled, s.Pushed, s.Conflicts)
+}
+
+// merger holds the conflict resolution settings and the running summary of a merge.
+type merger struct {
+	strategy     ConflictStrategy
+	localModTime time.Time
+	summary      *MergeSummary
+}
+
+// preferRemote reports whether a conflicting change should be taken from the remote task.
+func (mg *merger) preferRemote(remote *models.Task) bool {
+	switch mg.strategy {
+	case ConflictRemoteWins:
+		return true
+	case ConflictNewestWins:
+		return remote.Updated != nil && remote.Updated.After(mg.localModTime)
+	}
+	return false
+}
+
+// parentRef points to the parent of a task during a merge. Parents that already exist
+// remotely are referenced by ID; parents created locally since the last sync have no ID
+// yet and are referenced by their local task.
+type parentRef struct {
+	id    string
+	local *models.Task
+}
+
+// MergeTasklists performs a three-way merge of the local and remote versions of a task list
+// against their last-synced base. Edits made on only one side are taken from that side;
+// when both sides changed the same field, strategy decides the winner, comparing remote
+// modification times against localModTime for ConflictNewestWins. Tasks deleted on one
+// side are dropped unless the other side modified them since the base. The fields that the
+// local file format, described by caps, cannot hold are taken from the base, so that only
+// remote edits to them count.
+func MergeTasklists(base *ListState, localList *models.TaskList, remoteTasks []*models.Task, strategy ConflictStrategy, localModTime time.Time, caps format.Capabilities) (*models.TaskList, *MergeSummary) {
+	if base == nil {
+		base = &ListState{Tasks: make(map[string]*TaskState)}
+	}
+	mg := &merger{strategy: strategy, localModTime: localModTime, summary: &MergeSummary{}}
+
+	matches := matchTasks(localList.Tasks, remoteTasks)
+
+	remoteByID := make(map[string]*models.Task)
+	remoteParents := make(map[string]string)
+	var remoteOrder []*models.Task
+	var collectRemote func(tasks []*models.Task, parentID string)
+	collectRemote = func(tasks []*models.Task, parentID string) {
+		for _, rt := range tasks {
+			remoteByID[*rt.ID] = rt
+			remoteParents[*rt.ID] = parentID
+			remoteOrder = append(remoteOrder, rt)
+			collectRemote(rt.Children, *rt.ID)
+		}
+	}
+	collectRemote(remoteTasks, "")
+
+	localParents := make(map[*models.Task]*models.Task)
+	localDepths := make(map[*models.Task]int)
+	var localOrder []*models.Task
+	var collectLocal func(tasks []*models.Task, parent *models.Task, depth int)
+	collectLocal = func(tasks []*models.Task, parent *models.Task, depth int) {
+		for _, lt := range tasks {
+			localParents[lt] = parent
+			localDepths[lt] = depth
+			localOrder = append(localOrder, lt)
+			collectLocal(lt.Children, lt, depth+1)
+		}
+	}
+	collectLocal(localList.Tasks, nil, 0)
+
+	// identity returns the ID under which a local task is known to the base or the remote list.
+	identity := func(lt *models.Task) string {
+		if rt, ok := matches[lt]; ok {
+			return *rt.ID
+		}
+		if lt.ID != nil {
+			if _, ok := base.Tasks[*lt.ID]; ok {
+				return *lt.ID
+			}
+		}
+		return ""
+	}
+
+	// Fields the file format cannot hold count as unchanged locally
+	for _, lt := range localOrder {
+		if bt, ok := base.Tasks[identity(lt)]; ok {
+			if !caps.TitleSpacing && sameWords(lt.Title, bt.Title) {
+				lt.Title = bt.Title
+			}
+			if !caps.Notes || (!caps.NoteSpacing && sameNoteText(lt.Notes, bt.Notes)) {
+				lt.Notes = bt.Notes
+			}
+			if !caps.DueDates {
+				lt.Due, _ = models.ParseDate(bt.Due)
+			}
+		}
+	}
+
+	parentRefOf := func(parent *models.Task) parentRef {
+		if parent == nil {
+			return parentRef{}
+		}
+		if id := identity(parent); id != "" {
+			return parentRef{id: id}
+		}
+		return parentRef{local: parent}
+	}
+	// remoteParentRef returns the parent a local task gets on Google Tasks, where tasks nested
+	// deeper than MaxTaskDepth are moved up to the level of their ancestor at that depth. The
+	// file keeps its own nesting, which only counts as moved when this parent changes.
+	remoteParentRef := func(lt *models.Task) parentRef {
+		parent := localParents[lt]
+		for depth := localDepths[lt]; depth > MaxTaskDepth; depth-- {
+			parent = localParents[parent]
+		}
+		return parentRefOf(parent)
+	}
+
+	mergedByLocal := make(map[*models.Task]*models.Task)
+	mergedByID := make(map[string]*models.Task)
+	mergedParents := make(map[*models.Task]parentRef)
+	var mergedOrder []*models.Task
+	claimed := make(map[string]bool)
+
+	keep := func(m *models.Task, parent parentRef) {
+		if m.ID != nil {
+			mergedByID[*m.ID] = m
+		}
+		mergedParents[m] = parent
+		mergedOrder = append(mergedOrder, m)
+	}
+
+	for _, lt := range localOrder {
+		id := identity(lt)
+		parent := parentRefOf(localParents[lt])
+		limited := remoteParentRef(lt)
+		if id == "" {
+			m := copyTask(lt)
+			m.ID = nil
+			mergedByLocal[lt] = m
+			keep(m, parent)
+			mg.summary.Pushed.Created++
+			continue
+		}
+		claimed[id] = true
+
+		bt, inBase := base.Tasks[id]
+		rt, inRemote := remoteByID[id]
+
+		switch {
+		case inBase && inRemote:
+			var conflicts []string
+			localParent := parent
+			remoteParent := parentRef{id: remoteParents[id]}
+			localMoved := limited.local != nil || limited.id != bt.Parent
+			remoteMoved := remoteParent.id != bt.Parent
+			if !localMoved && remoteMoved {
+				parent, limited = remoteParent, remoteParent
+			} else if localMoved && remoteMoved && limited != remoteParent {
+				mg.summary.Conflicts++
+				if mg.preferRemote(rt) {
+					parent, limited = remoteParent, remoteParent
+				} else {
+					remoteParentTitle := "(top level)"
+					if remoteParent.id != "" {
+						remoteParentTitle = remoteByID[remoteParent.id].Title
+					}
+					conflicts = append(conflicts, conflictNote("parent", remoteParentTitle))
+				}
+			}
+
+			m := mg.mergeTask(bt, lt, rt, conflicts)
+			mergedByLocal[lt] = m
+			keep(m, parent)
+			if !sameTask(m, parent, lt, localParent) {
+				mg.summary.Pulled.Updated++
+			}
+			if !sameTask(m, limited, rt, remoteParent) {
+				mg.summary.Pushed.Updated++
+			}
+		case inRemote:
+			// Added on both sides since the base; the local version wins.
+			m := copyTask(lt)
+			m.ID = rt.ID
+			mergedByLocal[lt] = m
+			keep(m, parent)
+			if !sameTask(m, limited, rt, parentRef{id: remoteParents[id]}) {
+				mg.summary.Pushed.Updated++
+			}
+		default:
+			// Deleted remotely; recreate it only if it was edited locally.
+			if taskChanged(bt, lt, limited.id) || limited.local != nil {
+				m := copyTask(lt)
+				m.ID = nil
+				mergedByLocal[lt] = m
+				keep(m, parent)
+				mg.summary.Pushed.Created++
+			} else {
+				mg.summary.Pulled.Deleted++
+			}
+		}
+	}
+
+	var remoteOnly []*models.Task
+	for _, rt := range remoteOrder {
+		id := *rt.ID
+		if claimed[id] {
+			continue
+		}
+		parent := parentRef{id: remoteParents[id]}
+		if bt, inBase := base.Tasks[id]; inBase && !taskChanged(bt, rt, parent.id) {
+			// Deleted locally and untouched remotely.
+			mg.summary.Pushed.Deleted++
+			continue
+		}
+		m := copyTask(rt)
+		keep(m, parent)
+		remoteOnly = append(remoteOnly, m)
+		mg.summary.Pulled.Created++
+	}
+
+	// Resolve parents, refusing any assignment that would create a cycle.
+	resolved := make(map[*models.Task]*models.Task)
+	for _, m := range mergedOrder {
+		ref := mergedParents[m]
+		var parent *models.Task
+		if ref.local != nil {
+			parent = mergedByLocal[ref.local]
+		} else if ref.id != "" {
+			parent = mergedByID[ref.id]
+		}
+		for p := parent; p != nil; p = resolved[p] {
+			if p == m {
+				parent = nil
+				break
+			}
+		}
+		resolved[m] = parent
+	}
+
+	// Rebuild the hierarchy, keeping local order and placing remote-only tasks
+	// after the sibling that precedes them remotely.
+	children := make(map[*models.Task][]*models.Task)
+	isRemoteOnly := make(map[*models.Task]bool)
+	for _, m := range remoteOnly {
+		isRemoteOnly[m] = true
+	}
+	for _, m := range mergedOrder {
+		if !isRemoteOnly[m] {
+			parent := resolved[m]
+			children[parent] = append(children[parent], m)
+		}
+	}
+	for _, m := range remoteOnly {
+		parent := resolved[m]
+		siblings := children[parent]
+		index := 0
+		if prev := previousRemoteSibling(remoteByID[*m.ID], remoteByID, remoteParents, remoteTasks); prev != nil {
+			index = len(siblings)
+			for i, s := range siblings {
+				if s.ID != nil && *s.ID == *prev.ID {
+					index = i + 1
+					break
+				}
+			}
+		}
+		siblings = append(siblings, nil)
+		copy(siblings[index+1:], siblings[index:])
+		siblings[index] = m
+		children[parent] = siblings
+	}
+	for _, m := range mergedOrder {
+		m.Children = children[m]
+	}
+
+	return &models.TaskList{
+		ID:    localList.ID,
+		Title: localList.Title,
+		Tasks: children[nil],
+	}, mg.summary
+}
+
+// ReconcileTasklist merges a local list with its remote counterpart against the last-synced base,
+// pushes the result to Google Tasks, writes it back to filePath and records it as the new base.
+func ReconcileTasklist(localList *models.TaskList, filePath string, remoteList *models.TaskList, client *api.GoogleTasksClient, state *State, opts SyncOptions) (*MergeSummary, error) {
+	remoteTasks, err := client.GetTasks(*remoteList.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, summary := mergeLocalFile(localList, filePath, remoteTasks, state.Lists[*remoteList.ID], opts)
+	if merged.Title == "" {
+		merged.Title = remoteList.Title
+	}
+	now := time.Now().UTC()
+	merged.ID = remoteList.ID
+	merged.SyncedAt = &now
+	merged.Metadata = localList.Metadata
+	merged.FrontMatter = localList.FrontMatter
+
+	if err := SyncTasklist(merged, filePath, *remoteList.ID, client, opts); err != nil {
+		return nil, err
+	}
+	merged.Content = carryContent(localList, merged)
+	if err := saveFile(merged, filePath, opts); err != nil {
+		return nil, fmt.Errorf("failed to save to file: %v", err)
+	}
+
+	state.Record(*remoteList.ID, remoteList.Title, filePath, merged.Tasks)
+	return summary, nil
+}
+
+// mergeLocalFile merges a list loaded from filePath with the remote tasks, using the
+// modification time of the file as the time of the local edits, and the conflict strategy and
+// file format of opts.
+func mergeLocalFile(localList *models.TaskList, filePath string, remoteTasks []*models.Task, base *ListState, opts SyncOptions) (*models.TaskList, *MergeSummary) {
+	var localModTime time.Time
+	if info, err := os.Stat(filePath); err == nil {
+		localModTime = info.ModTime()
+	}
+	return MergeTasklists(base, localList, remoteTasks, opts.Conflict, localModTime, formatOf(opts).Capabilities())
+}
+
+// mergeTask merges the fields of a task that exists on both sides. Conflicts already found
+// by the caller, such as a conflicting parent, are passed in so that they can be marked.
+func (mg *merger) mergeTask(base *TaskState, local *models.Task, remote *models.Task, conflicts []string) *models.Task {
+	merged := &models.Task{
+		ID:      remote.ID,
+		Updated: remote.Updated,
+	}
+	merged.Title = mergeField(mg, &conflicts, "title", base.Title, local.Title, remote.Title, remote)
+	merged.Status = mergeField(mg, &conflicts, "status", base.Status, local.Status, remote.Status, remote)
+	notes := mergeField(mg, &conflicts, "notes", models.StringValue(base.Notes), models.StringValue(local.Notes), models.StringValue(remote.Notes), remote)
+	due := mergeField(mg, &conflicts, "due date", base.Due, local.DueDate(), remote.DueDate(), remote)
+	merged.Due, _ = models.ParseDate(due)
+
+	// Google Tasks sets the completion time itself; keep the local one until it has
+	if merged.Status == "completed" {
+		merged.Completed = remote.Completed
+		if merged.Completed == nil {
+			merged.Completed = local.Completed
+		}
+	}
+
+	if mg.strategy == ConflictMark {
+		for _, conflict := range conflicts {
+			if notes != "" {
+				notes += "\n"
+			}
+			notes += conflict
+		}
+	}
+	if notes != "" {
+		merged.Notes = &notes
+	}
+	return merged
+}
+
+// mergeField returns the remote value if the local side left the field untouched, the local
+// value if the remote side did, and otherwise resolves the conflict using the merge strategy.
+func mergeField[T comparable](mg *merger, conflicts *[]string, field string, base, local, remote T, remoteTask *models.Task) T {
+	if local == base || local == remote {
+		return remote
+	}
+	if remote == base {
+		return local
+	}
+
+	mg.summary.Conflicts++
+	if mg.preferRemote(remoteTask) {
+		return remote
+	}
+	*conflicts = append(*conflicts, conflictNote(field, fmt.Sprint(remote)))
+	return local
+}
+
+// conflictNote describes a conflicting remote value that was not applied.
+func conflictNote(field string, remoteValue string) string {
+	return fmt.Sprintf("CONFLICT: remote %s was %q", field, remoteValue)
+}
+
+// taskChanged reports whether a task differs from its last-synced version.
+func taskChanged(base *TaskState, task *models.Task, parentID string) bool {
+	return base.Title != task.Title ||
+		base.Status != task.Status ||
+		models.StringValue(base.Notes) != models.StringValue(task.Notes) ||
+		base.Due != task.DueDate() ||
+		base.Parent != parentID
+}
+
+// previousRemoteSibling returns the task preceding rt under the same remote parent, if any.
+func previousRemoteSibling(rt *models.Task, remoteByID map[string]*models.Task, remoteParents map[string]string, remoteTasks []*models.Task) *models.Task {
+	siblings := remoteTasks
+	if parentID := remoteParents[*rt.ID]; parentID != "" {
+		siblings = remoteByID[parentID].Children
+	}
+	for i, s := range siblings {
+		if s == rt && i > 0 {
+			return siblings[i-1]
+		}
+	}
+	return nil
+}
+
+// sameTask reports whether two versions of a task have the same fields and parent.
+func sameTask(a *models.Task, aParent parentRef, b *models.Task, bParent parentRef) bool {
+	return a.Title == b.Title &&
+		a.Status == b.Status &&
+		models.StringValue(a.Notes) == models.StringValue(b.Notes) &&
+		a.DueDate() == b.DueDate() &&
+		aParent == bParent
+}
+
+func copyTask(task *models.Task) *models.Task {
+	return &models.Task{
+		ID:        task.ID,
+		Title:     task.Title,
+		Status:    task.Status,
+		Notes:     task.Notes,
+		Due:       task.Due,
+		Completed: task.Completed,
+		Updated:   task.Updated,
+	}
+}
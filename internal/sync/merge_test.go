@@ -0,0 +1,282 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/models"
+)
+
+func TestMergeTasklists(t *testing.T) {
+	base := &ListState{Tasks: map[string]*TaskState{
+		"a": {Title: "Write report", Status: "needsAction"},
+		"b": {Title: "Call back", Status: "needsAction"},
+		"c": {Title: "Old idea", Status: "needsAction"},
+		"d": {Title: "Book flights", Status: "needsAction"},
+	}}
+
+	localList := &models.TaskList{
+		Title: "Work",
+		Tasks: []*models.Task{
+			// Renamed locally, completed remotely
+			{ID: strPtr("a"), Title: "Write quarterly report", Status: "needsAction"},
+			// Untouched locally, deleted remotely
+			{ID: strPtr("b"), Title: "Call back", Status: "needsAction"},
+			// Edited locally, deleted remotely
+			{ID: strPtr("d"), Title: "Book flights", Status: "completed"},
+			// New locally
+			{Title: "Draft agenda", Status: "needsAction"},
+			// "c" deleted locally, untouched remotely
+		},
+	}
+
+	remoteTasks := []*models.Task{
+		{ID: strPtr("e"), Title: "Reply to email", Status: "needsAction"},
+		{ID: strPtr("a"), Title: "Write report", Status: "completed"},
+		{ID: strPtr("c"), Title: "Old idea", Status: "needsAction"},
+	}
+
+	merged, _ := MergeTasklists(base, localList, remoteTasks, ConflictLocalWins, time.Time{}, format.FullCapabilities)
+
+	var titles []string
+	for _, task := range merged.Tasks {
+		titles = append(titles, task.Title)
+	}
+	expected := []string{"Reply to email", "Write quarterly report", "Book flights", "Draft agenda"}
+	if len(titles) != len(expected) {
+		t.Fatalf("Expected tasks %v, got %v", expected, titles)
+	}
+	for i := range expected {
+		if titles[i] != expected[i] {
+			t.Fatalf("Expected tasks %v, got %v", expected, titles)
+		}
+	}
+
+	report := merged.Tasks[1]
+	if report.ID == nil || *report.ID != "a" || report.Status != "completed" {
+		t.Errorf("Expected both sides' edits to survive on task 'a', got %+v", report)
+	}
+	if flights := merged.Tasks[2]; flights.ID != nil || flights.Status != "completed" {
+		t.Errorf("Expected locally edited task to be recreated, got %+v", flights)
+	}
+}
+
+func TestMergeTasklistsConflictingParents(t *testing.T) {
+	base := &ListState{Tasks: map[string]*TaskState{
+		"a": {Title: "A", Status: "needsAction"},
+		"b": {Title: "B", Status: "needsAction"},
+	}}
+
+	// Locally B is moved under A, remotely A is moved under B.
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "A", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("b"), Title: "B", Status: "needsAction"},
+		}},
+	}}
+	remoteTasks := []*models.Task{
+		{ID: strPtr("b"), Title: "B", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+		}},
+	}
+
+	merged, _ := MergeTasklists(base, localList, remoteTasks, ConflictLocalWins, time.Time{}, format.FullCapabilities)
+
+	if len(merged.Tasks) != 1 || len(merged.Tasks[0].Children) != 1 {
+		t.Fatalf("Expected a single task with one subtask, got %+v", merged.Tasks)
+	}
+}
+
+func TestMergeTasklistsConflictStrategies(t *testing.T) {
+	base := &ListState{Tasks: map[string]*TaskState{
+		"a": {Title: "Call back", Status: "needsAction"},
+	}}
+	localModTime := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	remoteUpdated := localModTime.Add(time.Hour)
+
+	tests := []struct {
+		strategy      ConflictStrategy
+		expectedTitle string
+		expectedNotes string
+	}{
+		{ConflictLocalWins, "Call back Anna", ""},
+		{ConflictRemoteWins, "Call back Bob", ""},
+		{ConflictNewestWins, "Call back Bob", ""},
+		{ConflictMark, "Call back Anna", `CONFLICT: remote title was "Call back Bob"`},
+	}
+
+	for _, tt := range tests {
+		localList := &models.TaskList{Tasks: []*models.Task{
+			{ID: strPtr("a"), Title: "Call back Anna", Status: "needsAction"},
+		}}
+		remoteTasks := []*models.Task{
+			{ID: strPtr("a"), Title: "Call back Bob", Status: "completed", Updated: &remoteUpdated},
+		}
+
+		merged, summary := MergeTasklists(base, localList, remoteTasks, tt.strategy, localModTime, format.FullCapabilities)
+
+		task := merged.Tasks[0]
+		if task.Title != tt.expectedTitle {
+			t.Errorf("%s: expected title '%s', got '%s'", tt.strategy, tt.expectedTitle, task.Title)
+		}
+		if task.Status != "completed" {
+			t.Errorf("%s: expected the remote status change to survive, got '%s'", tt.strategy, task.Status)
+		}
+		if models.StringValue(task.Notes) != tt.expectedNotes {
+			t.Errorf("%s: expected notes '%s', got '%s'", tt.strategy, tt.expectedNotes, models.StringValue(task.Notes))
+		}
+		if summary.Conflicts != 1 {
+			t.Errorf("%s: expected 1 conflict, got %d", tt.strategy, summary.Conflicts)
+		}
+	}
+}
+
+func TestMergeTasklistsDueDates(t *testing.T) {
+	base := &ListState{Tasks: map[string]*TaskState{
+		"a": {Title: "Submit budget", Status: "needsAction", Due: "2026-10-20"},
+		"b": {Title: "Book venue", Status: "needsAction"},
+	}}
+
+	localDue, _ := models.ParseDate("2026-10-27")
+	localList := &models.TaskList{Tasks: []*models.Task{
+		// Postponed locally
+		{ID: strPtr("a"), Title: "Submit budget", Status: "needsAction", Due: localDue},
+		// Untouched locally, given a due date remotely
+		{ID: strPtr("b"), Title: "Book venue", Status: "needsAction"},
+	}}
+
+	baseDue, _ := models.ParseDate("2026-10-20")
+	remoteDue, _ := models.ParseDate("2026-11-02")
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Submit budget", Status: "needsAction", Due: baseDue},
+		{ID: strPtr("b"), Title: "Book venue", Status: "needsAction", Due: remoteDue},
+	}
+
+	merged, _ := MergeTasklists(base, localList, remoteTasks, ConflictLocalWins, time.Time{}, format.FullCapabilities)
+
+	if due := merged.Tasks[0].DueDate(); due != "2026-10-27" {
+		t.Errorf("Expected the local due date to be kept, got '%s'", due)
+	}
+	if due := merged.Tasks[1].DueDate(); due != "2026-11-02" {
+		t.Errorf("Expected the remote due date to be pulled, got '%s'", due)
+	}
+}
+
+func TestMergeTasklistsKeepsUnsupportedFields(t *testing.T) {
+	notes := "Milk, Eggs"
+	edited := "Milk, Eggs, Bread"
+	base := &ListState{Tasks: map[string]*TaskState{
+		"a": {Title: "Groceries", Status: "needsAction", Notes: &notes},
+		"b": {Title: "Call back", Status: "needsAction", Notes: &notes},
+	}}
+	// The file format has no notes, so the local tasks come without them
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Groceries", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "Call back", Status: "completed"},
+	}}
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Groceries", Status: "needsAction", Notes: &notes},
+		{ID: strPtr("b"), Title: "Call back", Status: "needsAction", Notes: &edited},
+	}
+
+	merged, _ := MergeTasklists(base, localList, remoteTasks, ConflictLocalWins, time.Time{}, format.Capabilities{TaskIDs: true, Subtasks: true})
+
+	if merged.Tasks[0].Notes == nil || *merged.Tasks[0].Notes != notes {
+		t.Errorf("Expected the notes to be kept, got %v", merged.Tasks[0].Notes)
+	}
+	if merged.Tasks[1].Notes == nil || *merged.Tasks[1].Notes != edited || merged.Tasks[1].Status != "completed" {
+		t.Errorf("Expected the remote notes and the local status, got %+v", merged.Tasks[1])
+	}
+}
+
+func TestTodoTxtRoundTripKeepsNotes(t *testing.T) {
+	notes := "Milk, Eggs"
+	childNotes := "Ask for a receipt"
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Groceries", Status: "needsAction", Notes: &notes, Children: []*models.Task{
+			{ID: strPtr("b"), Title: "Pay", Status: "needsAction", Notes: &childNotes, Parent: strPtr("a")},
+		}},
+	}
+	todo := format.TodoTxt{}
+	caps := todo.Capabilities()
+
+	// Export, then export again over the file with the sync state of the first export
+	data, err := todo.Encode(&models.TaskList{Title: "Errands", Tasks: remoteTasks})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	state := &State{Lists: make(map[string]*ListState)}
+	state.Record("list", "Errands", "errands.txt", remoteTasks)
+	localList, err := todo.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	merged, _ := MergeTasklists(state.Lists["list"], localList, remoteTasks, ConflictLocalWins, time.Time{}, caps)
+	if data, err = todo.Encode(merged); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Then import the file
+	localList, err = todo.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	plan := PlanTasklist(localList, remoteTasks, DeleteNever, caps)
+
+	if len(plan.Operations) != 0 {
+		t.Errorf("Expected no operations, got:\n%s", plan)
+	}
+	groceries := localList.Tasks[0]
+	if groceries.Notes == nil || *groceries.Notes != notes || len(groceries.Children) != 1 {
+		t.Fatalf("Expected the notes of the task to survive, got %+v", groceries)
+	}
+	if pay := groceries.Children[0]; pay.Notes == nil || *pay.Notes != childNotes {
+		t.Errorf("Expected the notes of the subtask to survive, got %v", pay.Notes)
+	}
+}
+
+func TestMergeTasklistsKeepsDeepNesting(t *testing.T) {
+	// The file nests a task deeper than Google Tasks allows, which holds it one level up
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Trip", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("b"), Title: "Pack", Status: "needsAction", Parent: strPtr("a")},
+			{ID: strPtr("c"), Title: "Socks", Status: "needsAction", Parent: strPtr("a")},
+		}},
+	}
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Trip", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("b"), Title: "Pack", Status: "needsAction", Children: []*models.Task{
+				{ID: strPtr("c"), Title: "Socks", Status: "needsAction"},
+			}},
+		}},
+	}}
+	state := &State{Lists: make(map[string]*ListState)}
+	state.Record("list", "Trip", "trip.md", localList.Tasks)
+	if parent := state.Lists["list"].Tasks["c"].Parent; parent != "a" {
+		t.Fatalf("Expected the deep task to be recorded under its parent on Google Tasks, got '%s'", parent)
+	}
+
+	merged, summary := MergeTasklists(state.Lists["list"], localList, remoteTasks, ConflictLocalWins, time.Time{}, format.FullCapabilities)
+
+	if summary.Pushed != (ChangeCounts{}) || summary.Pulled != (ChangeCounts{}) {
+		t.Errorf("Expected no changes, got %s", summary)
+	}
+	pack := merged.Tasks[0].Children
+	if len(pack) != 1 || len(pack[0].Children) != 1 || pack[0].Children[0].Title != "Socks" {
+		t.Fatalf("Expected the file's nesting to be kept, got %+v", merged.Tasks[0].Children)
+	}
+
+	var plan *Plan
+	if err := withDepthLimit(merged, NestingFlatten, func() error {
+		plan = PlanTasklist(merged, remoteTasks, DeleteAlways, format.FullCapabilities)
+		return nil
+	}); err != nil {
+		t.Fatalf("withDepthLimit failed: %v", err)
+	}
+	if len(plan.Operations) != 0 {
+		t.Errorf("Expected no operations for the flattened list, got:\n%s", plan)
+	}
+	if len(merged.Tasks[0].Children) != 1 || len(merged.Tasks[0].Children[0].Children) != 1 {
+		t.Errorf("Expected the nesting to be restored after planning, got %+v", merged.Tasks[0].Children)
+	}
+}
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+
+	"gtasks2md/internal/models"
+)
+
+// MaxTaskDepth is the deepest level of subtasks Google Tasks supports, top-level tasks being at depth 0.
+const MaxTaskDepth = 1
+
+// NestingPolicy decides what happens to local tasks nested deeper than Google Tasks allows.
+type NestingPolicy string
+
+const (
+	NestingFlatten NestingPolicy = "flatten"
+	NestingReject  NestingPolicy = "reject"
+)
+
+// ParseNestingPolicy validates a nesting policy name.
+func ParseNestingPolicy(name string) (NestingPolicy, error) {
+	switch policy := NestingPolicy(name); policy {
+	case NestingFlatten, NestingReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown nesting policy '%s' (expected flatten or reject)", name)
+}
+
+// loadTasklists loads the lists of a file and checks their tasks against the nesting policy
+// of opts. The lists keep their nesting; it is only limited when they are pushed to Google Tasks.
+// It also reports whether the file holds one list per section.
+func loadTasklists(filePath string, opts SyncOptions) ([]*models.TaskList, bool, error) {
+	localLists, sections, err := loadFile(filePath, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, localList := range localLists {
+		// Rejected nesting fails here, before anything is pushed
+		if err := withDepthLimit(localList, opts.Nesting, func() error { return nil }); err != nil {
+			return nil, false, fmt.Errorf("%s: %v", filePath, err)
+		}
+		if deep := countTooDeep(localList.Tasks, 0); deep > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %d tasks are nested deeper than Google Tasks allows; they are moved up to their nearest allowed parent on Google Tasks, and the file keeps its nesting\n", filePath, deep)
+		}
+	}
+	return localLists, sections, nil
+}
+
+// countTooDeep counts the tasks nested deeper than MaxTaskDepth, tasks being at the given depth.
+func countTooDeep(tasks []*models.Task, depth int) int {
+	count := 0
+	for _, task := range tasks {
+		if depth > MaxTaskDepth {
+			count++
+		}
+		count += countTooDeep(task.Children, depth+1)
+	}
+	return count
+}
+
+// withDepthLimit calls apply with the nesting of a list limited by limitDepth, and then
+// restores the list's own nesting, so that only what is pushed to Google Tasks is flattened.
+func withDepthLimit(list *models.TaskList, policy NestingPolicy, apply func() error) error {
+	tasks := list.Tasks
+	children := make(map[*models.Task][]*models.Task)
+	var save func(tasks []*models.Task)
+	save = func(tasks []*models.Task) {
+		for _, task := range tasks {
+			children[task] = task.Children
+			save(task.Children)
+		}
+	}
+	save(tasks)
+	defer func() {
+		list.Tasks = tasks
+		for task, saved := range children {
+			task.Children = saved
+		}
+	}()
+
+	if _, err := limitDepth(list, policy); err != nil {
+		return err
+	}
+	return apply()
+}
+
+// loadTasklistFor loads the local version of a remote list from a file: the single
+// list of the file, or the section with the list's ID or title. It returns nil if there is none.
+func loadTasklistFor(filePath string, remoteList *models.TaskList, opts SyncOptions) (*models.TaskList, error) {
+	localLists, sections, err := loadTasklists(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !sections {
+		return localLists[0], nil
+	}
+	return sectionFor(localLists, remoteList), nil
+}
+
+// sectionFor returns the local list with the ID of a remote list or, failing that, its title.
+func sectionFor(localLists []*models.TaskList, remoteList *models.TaskList) *models.TaskList {
+	for _, localList := range localLists {
+		if localList.ID != nil && remoteList.ID != nil && *localList.ID == *remoteList.ID {
+			return localList
+		}
+	}
+	for _, localList := range localLists {
+		if localList.Title == remoteList.Title {
+			return localList
+		}
+	}
+	return nil
+}
+
+// limitDepth enforces MaxTaskDepth on a list. With NestingFlatten (or the zero policy), tasks
+// nested too deeply become siblings of their ancestor at MaxTaskDepth, following it in document
+// order, and the number of tasks moved is returned. With NestingReject, an error names the first
+// offending task.
+func limitDepth(list *models.TaskList, policy NestingPolicy) (int, error) {
+	flattened := 0
+	var limit func(tasks []*models.Task, depth int) ([]*models.Task, error)
+	limit = func(tasks []*models.Task, depth int) ([]*models.Task, error) {
+		var result []*models.Task
+		for _, task := range tasks {
+			result = append(result, task)
+			if depth < MaxTaskDepth {
+				children, err := limit(task.Children, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				task.Children = children
+				continue
+			}
+
+			if len(task.Children) > 0 && policy == NestingReject {
+				return nil, fmt.Errorf("task '%s' is nested %d levels deep, but Google Tasks supports only %d level of subtasks", task.Children[0].Title, depth+1, MaxTaskDepth)
+			}
+			descendants := flatten(task.Children)
+			task.Children = nil
+			flattened += len(descendants)
+			result = append(result, descendants...)
+		}
+		return result, nil
+	}
+
+	tasks, err := limit(list.Tasks, 0)
+	if err != nil {
+		return 0, err
+	}
+	list.Tasks = tasks
+	return flattened, nil
+}
+
+// flatten lists tasks and all their descendants in document order, detaching the descendants.
+func flatten(tasks []*models.Task) []*models.Task {
+	var result []*models.Task
+	for _, task := range tasks {
+		children := task.Children
+		task.Children = nil
+		result = append(result, task)
+		result = append(result, flatten(children)...)
+	}
+	return result
+}
@@ -0,0 +1,427 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gtasks2md/internal/api"
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/models"
+)
+
+// OperationKind is the kind of change an Operation applies to a remote task.
+type OperationKind string
+
+const (
+	OperationCreate OperationKind = "create"
+	OperationUpdate OperationKind = "update"
+	OperationDelete OperationKind = "delete"
+	OperationMove   OperationKind = "move"
+)
+
+// DeletePolicy decides which remote tasks missing from the local list may be deleted.
+type DeletePolicy string
+
+const (
+	DeleteNever     DeletePolicy = "never"
+	DeleteCompleted DeletePolicy = "completed"
+	DeleteAlways    DeletePolicy = "always"
+)
+
+// ParseDeletePolicy validates a delete policy name.
+func ParseDeletePolicy(name string) (DeletePolicy, error) {
+	switch policy := DeletePolicy(name); policy {
+	case DeleteNever, DeleteCompleted, DeleteAlways:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown delete policy '%s' (expected never, completed or always)", name)
+}
+
+// allows reports whether the policy permits deleting the given remote task.
+// The zero policy behaves like DeleteAlways.
+func (p DeletePolicy) allows(task *models.Task) bool {
+	switch p {
+	case DeleteNever:
+		return false
+	case DeleteCompleted:
+		return task.Status == "completed"
+	}
+	return true
+}
+
+// Operation is a single change to be applied to a remote task list.
+type Operation struct {
+	Kind OperationKind
+	// Task is the local task to create or push. It is nil for deletions.
+	Task *models.Task
+	// Remote is the remote task to update or delete. It is nil for creations.
+	Remote *models.Task
+	// Parent is the local parent of a task to create or move, nil for top-level tasks.
+	// Its ID is only known once the parent itself has been created.
+	Parent *models.Task
+	// Previous is the local sibling a task is created or moved after, nil to place it first.
+	Previous *models.Task
+	// Changes lists the fields an update modifies.
+	Changes []string
+}
+
+func (op *Operation) String() string {
+	switch op.Kind {
+	case OperationCreate:
+		if op.Parent != nil {
+			return fmt.Sprintf("create '%s' under '%s'", op.Task.Title, op.Parent.Title)
+		}
+		return fmt.Sprintf("create '%s'", op.Task.Title)
+	case OperationUpdate:
+		return fmt.Sprintf("update '%s' (%s)", op.Remote.Title, strings.Join(op.Changes, ", "))
+	case OperationDelete:
+		return fmt.Sprintf("delete '%s'", op.Remote.Title)
+	case OperationMove:
+		destination := "the top level"
+		if op.Parent != nil {
+			destination = fmt.Sprintf("'%s'", op.Parent.Title)
+		}
+		if op.Previous != nil {
+			return fmt.Sprintf("move '%s' to %s after '%s'", op.Task.Title, destination, op.Previous.Title)
+		}
+		return fmt.Sprintf("move '%s' to the start of %s", op.Task.Title, destination)
+	}
+	return string(op.Kind)
+}
+
+// Plan is the ordered list of operations that brings a remote task list in line with a local one.
+type Plan struct {
+	ListName   string
+	Operations []*Operation
+
+	local   *models.TaskList
+	caps    format.Capabilities
+	matches map[*models.Task]*models.Task
+}
+
+func (p *Plan) String() string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Plan for list '%s':", p.ListName))
+	if len(p.Operations) == 0 {
+		lines = append(lines, "  no changes")
+	}
+	for _, op := range p.Operations {
+		lines = append(lines, "  "+op.String())
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// PlanTasklist computes the operations needed to make remoteTasks match localList without
+// touching Google Tasks. Subtasks that must outlive their remote parent are detached first,
+// then deletions follow, children before their parents, and finally creations, updates and
+// moves in local order, parents before their children. Remote tasks missing locally are
+// only deleted when the delete policy allows it, for them and for their missing subtasks.
+// The fields that the local file format, described by caps, cannot hold are taken from the
+// matching remote tasks, so that they are never patched.
+func PlanTasklist(localList *models.TaskList, remoteTasks []*models.Task, deletePolicy DeletePolicy, caps format.Capabilities) *Plan {
+	plan := &Plan{ListName: localList.Title, local: localList, caps: caps}
+
+	matches := matchTasks(localList.Tasks, remoteTasks)
+	plan.matches = matches
+	for lt, rt := range matches {
+		keepUnsupported(lt, rt, caps)
+	}
+
+	matchedIDs := make(map[string]bool)
+	for _, rt := range matches {
+		matchedIDs[*rt.ID] = true
+	}
+
+	remoteParents := make(map[string]string)
+	remoteIndex := make(map[string]int)
+	var indexRemote func(tasks []*models.Task, parentID string)
+	indexRemote = func(tasks []*models.Task, parentID string) {
+		for i, rt := range tasks {
+			remoteParents[*rt.ID] = parentID
+			remoteIndex[*rt.ID] = i
+			indexRemote(rt.Children, *rt.ID)
+		}
+	}
+	indexRemote(remoteTasks, "")
+
+	localParents := make(map[*models.Task]*models.Task)
+	var collectLocal func(tasks []*models.Task, parent *models.Task)
+	collectLocal = func(tasks []*models.Task, parent *models.Task) {
+		for _, lt := range tasks {
+			localParents[lt] = parent
+			collectLocal(lt.Children, lt)
+		}
+	}
+	collectLocal(localList.Tasks, nil)
+
+	localByRemoteID := make(map[string]*models.Task)
+	for lt, rt := range matches {
+		localByRemoteID[*rt.ID] = lt
+	}
+
+	// A remote task missing locally is deleted when the policy allows it, unless one of
+	// its subtasks is missing too but protected: deleting the parent would take it along.
+	var deletable func(rt *models.Task) bool
+	deletable = func(rt *models.Task) bool {
+		if matchedIDs[*rt.ID] || !deletePolicy.allows(rt) {
+			return false
+		}
+		for _, child := range rt.Children {
+			if !matchedIDs[*child.ID] && !deletable(child) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Subtasks whose remote parent is about to be deleted or demoted into a subtask itself
+	// are moved to the top level first, so that they are neither deleted with their parent
+	// nor left nested too deeply. They are put in their final place afterwards. Each one
+	// lands at the start of the top level, ahead of those detached before it.
+	detached := 0
+	for _, rt := range remoteTasks {
+		parentLocal, parentKept := localByRemoteID[*rt.ID]
+		parentLeaves := deletable(rt) || (parentKept && localParents[parentLocal] != nil)
+		for _, child := range rt.Children {
+			lt, kept := localByRemoteID[*child.ID]
+			if kept && parentLeaves && localParents[lt] != parentLocal {
+				detached++
+				remoteParents[*child.ID] = ""
+				remoteIndex[*child.ID] = -detached
+				plan.Operations = append(plan.Operations, &Operation{
+					Kind:   OperationMove,
+					Task:   lt,
+					Remote: child,
+				})
+			}
+		}
+	}
+
+	// Delete remote tasks that are not matched by any local task
+	// Delete children first
+	for _, rt := range remoteTasks {
+		for _, child := range rt.Children {
+			if deletable(child) {
+				plan.Operations = append(plan.Operations, &Operation{Kind: OperationDelete, Remote: child})
+			}
+		}
+		if deletable(rt) {
+			plan.Operations = append(plan.Operations, &Operation{Kind: OperationDelete, Remote: rt})
+		}
+	}
+
+	// Create, update, reorder and reparent tasks
+	var planChildren func(tasks []*models.Task, parent *models.Task)
+	planChildren = func(tasks []*models.Task, parent *models.Task) {
+		// Only tasks that already live under this parent remotely can stay in place
+		parentID, parentExists := "", true
+		if parent != nil {
+			if rp, ok := matches[parent]; ok {
+				parentID = *rp.ID
+			} else {
+				parentExists = false
+			}
+		}
+		underParent := func(lt *models.Task) bool {
+			rt, ok := matches[lt]
+			return ok && parentExists && remoteParents[*rt.ID] == parentID
+		}
+
+		var siblings []*models.Task
+		var indices []int
+		for _, lt := range tasks {
+			if underParent(lt) {
+				siblings = append(siblings, lt)
+				indices = append(indices, remoteIndex[*matches[lt].ID])
+			}
+		}
+		inPlace := make(map[*models.Task]bool)
+		for i, keep := range longestIncreasingRun(indices) {
+			if keep {
+				inPlace[siblings[i]] = true
+			}
+		}
+
+		var previous *models.Task
+		for _, lt := range tasks {
+			if remoteTask, exists := matches[lt]; exists {
+				// Only patch tasks whose fields changed; parent and position changes are moves
+				if changes := lt.Diff(remoteTask); len(changes) > 0 {
+					plan.Operations = append(plan.Operations, &Operation{
+						Kind:    OperationUpdate,
+						Task:    lt,
+						Remote:  remoteTask,
+						Changes: changes,
+					})
+				}
+				if !inPlace[lt] {
+					plan.Operations = append(plan.Operations, &Operation{
+						Kind:     OperationMove,
+						Task:     lt,
+						Remote:   remoteTask,
+						Parent:   parent,
+						Previous: previous,
+					})
+				}
+			} else {
+				plan.Operations = append(plan.Operations, &Operation{
+					Kind:     OperationCreate,
+					Task:     lt,
+					Parent:   parent,
+					Previous: previous,
+				})
+			}
+
+			previous = lt
+			planChildren(lt.Children, lt)
+		}
+	}
+
+	planChildren(localList.Tasks, nil)
+
+	return plan
+}
+
+// ExecutePlan applies the operations of a plan to the remote task list, assigning
+// remote IDs to the local tasks as they are created or updated. Deleted tasks are
+// recorded in trash, if set, which is saved right away so that they can be restored
+// even if a later list fails. Large plans are applied through batch requests.
+func ExecutePlan(plan *Plan, remoteListID string, client *api.GoogleTasksClient, trash *Trash) error {
+	if trash == nil {
+		return executePlan(plan, remoteListID, client, nil)
+	}
+
+	trashed := len(trash.Tasks)
+	err := executePlan(plan, remoteListID, client, trash)
+	if len(trash.Tasks) > trashed {
+		if saveErr := trash.Save(); saveErr != nil && err == nil {
+			return fmt.Errorf("failed to save trash: %v", saveErr)
+		}
+	}
+	return err
+}
+
+func executePlan(plan *Plan, remoteListID string, client *api.GoogleTasksClient, trash *Trash) error {
+	for localTask, remoteTask := range plan.matches {
+		localTask.ID = remoteTask.ID
+	}
+
+	if len(plan.Operations) > batchThreshold {
+		return executeBatched(plan, remoteListID, client, trash)
+	}
+
+	for _, op := range plan.Operations {
+		switch op.Kind {
+		case OperationDelete:
+			if err := client.DeleteTask(remoteListID, *op.Remote.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete task '%s': %v\n", op.Remote.Title, err)
+			} else if trash != nil {
+				trash.Add(remoteListID, plan.ListName, op.Remote)
+			}
+		case OperationUpdate:
+			op.Remote.Title = op.Task.Title
+			op.Remote.Status = op.Task.Status
+			op.Remote.Notes = op.Task.Notes
+			op.Remote.Due = op.Task.Due
+			updated, err := client.UpdateTask(remoteListID, op.Remote)
+			if err != nil {
+				return err
+			}
+			op.Task.ID = updated.ID
+		case OperationCreate:
+			created, err := client.CreateTask(remoteListID, op.Task, taskID(op.Parent), taskID(op.Previous))
+			if err != nil {
+				return err
+			}
+			op.Task.ID = created.ID
+		case OperationMove:
+			if _, err := client.MoveTask(remoteListID, *op.Remote.ID, taskID(op.Parent), taskID(op.Previous)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepUnsupported copies the fields a file format cannot hold from the remote version of a
+// task into its local version.
+func keepUnsupported(local *models.Task, remote *models.Task, caps format.Capabilities) {
+	if !caps.TitleSpacing && sameWords(local.Title, remote.Title) {
+		local.Title = remote.Title
+	}
+	if !caps.Notes || (!caps.NoteSpacing && sameNoteText(local.Notes, remote.Notes)) {
+		local.Notes = remote.Notes
+	}
+	if !caps.DueDates {
+		local.Due = remote.Due
+	}
+	if !caps.CompletionDates {
+		local.Completed = remote.Completed
+	}
+}
+
+// sameWords reports whether two titles only differ in their whitespace.
+func sameWords(a string, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// sameNoteText reports whether two notes only differ in their blank lines at the start and end.
+func sameNoteText(a *string, b *string) bool {
+	trim := func(notes *string) string {
+		lines := strings.Split(models.StringValue(notes), "\n")
+		for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+			lines = lines[1:]
+		}
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		return strings.Join(lines, "\n")
+	}
+	return trim(a) == trim(b)
+}
+
+// taskID returns the ID of a task, or an empty string for a nil task.
+func taskID(task *models.Task) string {
+	if task == nil || task.ID == nil {
+		return ""
+	}
+	return *task.ID
+}
+
+// longestIncreasingRun marks the longest strictly increasing subsequence of values.
+// Siblings whose remote positions form this subsequence are already in the right
+// relative order, so only the others need to be moved.
+func longestIncreasingRun(values []int) []bool {
+	// tails[k] is the index of the smallest value ending an increasing run of length k+1
+	var tails []int
+	prev := make([]int, len(values))
+	for i, v := range values {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if values[tails[mid]] < v {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+
+	keep := make([]bool, len(values))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			keep[i] = true
+		}
+	}
+	return keep
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gtasks2md/internal/models"
+)
+
+const (
+	stateDirName  = ".gtasks2md"
+	stateFileName = "state.json"
+)
+
+// TaskState is the last-synced version of a single task.
+type TaskState struct {
+	Title  string  `json:"title"`
+	Status string  `json:"status"`
+	Notes  *string `json:"notes,omitempty"`
+	Due    string  `json:"due,omitempty"`
+	Parent string  `json:"parent,omitempty"`
+}
+
+// ListState is the last-synced version of a task list, used as the base of a three-way merge.
+type ListState struct {
+	Title    string                `json:"title"`
+	File     string                `json:"file"`
+	SyncedAt time.Time             `json:"synced_at"`
+	Tasks    map[string]*TaskState `json:"tasks"`
+}
+
+// State holds the sync state of every list exported to or imported from a directory.
+type State struct {
+	Lists map[string]*ListState `json:"lists"`
+
+	path string
+}
+
+// LoadState loads the sync state of the Markdown files in dir. A missing state file yields an empty state.
+func LoadState(dir string) (*State, error) {
+	state := &State{
+		Lists: make(map[string]*ListState),
+		path:  filepath.Join(dir, stateDirName, stateFileName),
+	}
+
+	data, err := os.ReadFile(state.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("unable to read sync state: %v", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to parse sync state %s: %v", state.path, err)
+	}
+	if state.Lists == nil {
+		state.Lists = make(map[string]*ListState)
+	}
+	return state, nil
+}
+
+// Save writes the sync state back to disk.
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("unable to create sync state directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode sync state: %v", err)
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0644)
+}
+
+// Record stores tasks as the last-synced version of the list with the given ID.
+// Tasks without an ID have never been synced and are skipped.
+func (s *State) Record(listID string, title string, filePath string, tasks []*models.Task) {
+	listState := &ListState{
+		Title:    title,
+		File:     filepath.Base(filePath),
+		SyncedAt: time.Now().UTC(),
+		Tasks:    make(map[string]*TaskState),
+	}
+
+	// Tasks nested deeper than MaxTaskDepth are recorded under the parent they have on Google Tasks
+	var record func(tasks []*models.Task, parentID string, depth int)
+	record = func(tasks []*models.Task, parentID string, depth int) {
+		for _, t := range tasks {
+			if t.ID == nil || *t.ID == "" {
+				continue
+			}
+			listState.Tasks[*t.ID] = newTaskState(t, parentID)
+			if depth < MaxTaskDepth {
+				record(t.Children, *t.ID, depth+1)
+			} else {
+				record(t.Children, parentID, depth+1)
+			}
+		}
+	}
+	record(tasks, "", 0)
+
+	s.Lists[listID] = listState
+}
+
+func newTaskState(task *models.Task, parentID string) *TaskState {
+	return &TaskState{
+		Title:  task.Title,
+		Status: task.Status,
+		Notes:  task.Notes,
+		Due:    task.DueDate(),
+		Parent: parentID,
+	}
+}
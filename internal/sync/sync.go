@@ -6,98 +6,210 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unicode"
 
 	"gtasks2md/internal/api"
+	"gtasks2md/internal/format"
 	"gtasks2md/internal/markdown"
 	"gtasks2md/internal/models"
 )
 
-// SyncTasklist Syncs a local TaskList to a remote Google Task list.
-func SyncTasklist(localList *models.TaskList, remoteListID string, client *api.GoogleTasksClient) error {
+// SyncOptions controls how local changes are applied to Google Tasks.
+type SyncOptions struct {
+	// DryRun prints the planned operations instead of applying them.
+	DryRun bool
+	// Delete decides which remote tasks missing locally may be deleted.
+	Delete DeletePolicy
+	// Conflict decides which side wins when a task was changed on both sides.
+	Conflict ConflictStrategy
+	// Trash receives the remote tasks deleted by the sync, if set.
+	Trash *Trash
+	// MaxDeletePercent and MaxDeleteCount abort the sync of a list that would delete more
+	// than this share or number of its remote tasks. Zero disables the respective limit.
+	MaxDeletePercent int
+	MaxDeleteCount   int
+	// Force applies deletions even beyond the limits.
+	Force bool
+	// Nesting decides what happens to local tasks nested deeper than Google Tasks allows.
+	Nesting NestingPolicy
+	// Markdown controls how merged lists are written back to their files.
+	Markdown markdown.SerializerOptions
+	// Format is the format of the local files, Markdown when unset.
+	Format format.Format
+}
+
+const (
+	DefaultMaxDeletePercent = 50
+	DefaultMaxDeleteCount   = 25
+)
+
+// SyncTasklist Syncs a local TaskList, read from filePath, to a remote Google Task list. Tasks
+// nested deeper than Google Tasks allows are pushed as the nesting policy of opts decides.
+func SyncTasklist(localList *models.TaskList, filePath string, remoteListID string, client *api.GoogleTasksClient, opts SyncOptions) error {
 	remoteTasks, err := client.GetTasks(remoteListID)
 	if err != nil {
 		return err
 	}
 
-	// Map remote tasks by title
-	remoteMap := make(map[string]*models.Task)
-	for _, rt := range remoteTasks {
-		remoteMap[rt.Title] = rt
-		for _, child := range rt.Children {
-			remoteMap[child.Title] = child
+	return withDepthLimit(localList, opts.Nesting, func() error {
+		plan := PlanTasklist(localList, remoteTasks, opts.Delete, formatOf(opts).Capabilities())
+		if err := checkDeletions(plan, filePath, remoteTasks, opts); err != nil {
+			return err
 		}
+		return ExecutePlan(plan, remoteListID, client, opts.Trash)
+	})
+}
+
+// checkDeletions guards against wiping a remote list, for instance when the local file at
+// filePath could not be parsed as expected. Deleting a single task never trips the percentage limit.
+func checkDeletions(plan *Plan, filePath string, remoteTasks []*models.Task, opts SyncOptions) error {
+	if opts.Force {
+		return nil
 	}
 
-	// Collect all local titles to find tasks to delete
-	localTitles := make(map[string]bool)
-	var collectTitles func(tasks []*models.Task)
-	collectTitles = func(tasks []*models.Task) {
+	var deleted []string
+	for _, op := range plan.Operations {
+		if op.Kind == OperationDelete {
+			deleted = append(deleted, fmt.Sprintf("'%s'", op.Remote.Title))
+		}
+	}
+
+	total := 0
+	var count func(tasks []*models.Task)
+	count = func(tasks []*models.Task) {
 		for _, t := range tasks {
-			localTitles[t.Title] = true
-			collectTitles(t.Children)
+			total++
+			count(t.Children)
 		}
 	}
-	collectTitles(localList.Tasks)
+	count(remoteTasks)
 
-	// Delete remote tasks that are not in local list
-	// Delete children first
-	for _, rt := range remoteTasks {
-		for _, child := range rt.Children {
-			if !localTitles[child.Title] {
-				if err := client.DeleteTask(remoteListID, *child.ID); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: failed to delete subtask '%s': %v\n", child.Title, err)
-				}
-			}
+	tooMany := opts.MaxDeleteCount > 0 && len(deleted) > opts.MaxDeleteCount
+	tooLarge := opts.MaxDeletePercent > 0 && len(deleted) > 1 && len(deleted)*100 > total*opts.MaxDeletePercent
+	if !tooMany && !tooLarge {
+		return nil
+	}
+
+	return fmt.Errorf("refusing to delete %d of %d tasks from list '%s' (limits: %d%% or %d tasks): %s; check %s (%s format) or re-run with --force",
+		len(deleted), total, plan.ListName, opts.MaxDeletePercent, opts.MaxDeleteCount, strings.Join(deleted, ", "), filePath, formatOf(opts).Name())
+}
+
+// matchTasks pairs local tasks with their remote counterparts.
+// Tasks are matched by ID first. Local tasks without a known ID fall back to their title:
+// the Nth unmatched task with a given title under a parent is paired with the Nth unmatched
+// remote task with that title under the same parent, so repeated titles are kept apart.
+// Tasks that changed parent are then matched across the list, but only when their title
+// is unambiguous.
+func matchTasks(localTasks []*models.Task, remoteTasks []*models.Task) map[*models.Task]*models.Task {
+	remoteByID := make(map[string]*models.Task)
+	remoteParents := make(map[*models.Task]*models.Task)
+	var remoteOrder []*models.Task
+	var collectRemote func(tasks []*models.Task, parent *models.Task)
+	collectRemote = func(tasks []*models.Task, parent *models.Task) {
+		for _, rt := range tasks {
+			remoteByID[*rt.ID] = rt
+			remoteParents[rt] = parent
+			remoteOrder = append(remoteOrder, rt)
+			collectRemote(rt.Children, rt)
 		}
-		if !localTitles[rt.Title] {
-			if err := client.DeleteTask(remoteListID, *rt.ID); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to delete task '%s': %v\n", rt.Title, err)
-			}
+	}
+	collectRemote(remoteTasks, nil)
+
+	localParents := make(map[*models.Task]*models.Task)
+	var localOrder []*models.Task
+	var collectLocal func(tasks []*models.Task, parent *models.Task)
+	collectLocal = func(tasks []*models.Task, parent *models.Task) {
+		for _, t := range tasks {
+			localParents[t] = parent
+			localOrder = append(localOrder, t)
+			collectLocal(t.Children, t)
 		}
 	}
+	collectLocal(localTasks, nil)
 
-	// Create or update tasks
-	var syncTask func(localTask *models.Task, parentID string) error
-	syncTask = func(localTask *models.Task, parentID string) error {
-		if remoteTask, exists := remoteMap[localTask.Title]; exists {
-			// Update
-			remoteTask.Status = localTask.Status
-			remoteTask.Notes = localTask.Notes
-			updated, err := client.UpdateTask(remoteListID, remoteTask)
-			if err != nil {
-				return err
+	matches := make(map[*models.Task]*models.Task)
+	claimed := make(map[string]bool)
+
+	// Match by ID
+	for _, lt := range localOrder {
+		if lt.ID == nil {
+			continue
+		}
+		if rt, ok := remoteByID[*lt.ID]; ok && !claimed[*rt.ID] {
+			matches[lt] = rt
+			claimed[*rt.ID] = true
+		}
+	}
+
+	// Fall back to title for tasks that are new to the remote list
+	var unmatched []*models.Task
+	for _, lt := range localOrder {
+		if _, ok := matches[lt]; ok {
+			continue
+		}
+		if lt.ID != nil {
+			if _, known := remoteByID[*lt.ID]; known {
+				continue
 			}
-			localTask.ID = updated.ID
-		} else {
-			// Create
-			created, err := client.CreateTask(remoteListID, localTask, parentID)
-			if err != nil {
-				return err
+		}
+		unmatched = append(unmatched, lt)
+	}
+
+	// Match by title under the same parent. Local order is parents first, so the match of
+	// a local parent is always known before its children are considered.
+	var stillUnmatched []*models.Task
+	for _, lt := range unmatched {
+		var remoteParent *models.Task
+		if lp := localParents[lt]; lp != nil {
+			rp, ok := matches[lp]
+			if !ok {
+				stillUnmatched = append(stillUnmatched, lt)
+				continue
 			}
-			localTask.ID = created.ID
+			remoteParent = rp
 		}
 
-		// Sync children
-		for _, child := range localTask.Children {
-			if err := syncTask(child, *localTask.ID); err != nil {
-				return err
+		matched := false
+		for _, rt := range remoteOrder {
+			if !claimed[*rt.ID] && rt.Title == lt.Title && remoteParents[rt] == remoteParent {
+				matches[lt] = rt
+				claimed[*rt.ID] = true
+				matched = true
+				break
 			}
 		}
-		return nil
+		if !matched {
+			stillUnmatched = append(stillUnmatched, lt)
+		}
 	}
 
-	for _, task := range localList.Tasks {
-		if err := syncTask(task, ""); err != nil {
-			return err
+	// Match tasks moved to another parent when the title is unique on both sides
+	localTitleCount := make(map[string]int)
+	for _, lt := range stillUnmatched {
+		localTitleCount[lt.Title]++
+	}
+	remoteByTitle := make(map[string][]*models.Task)
+	for _, rt := range remoteOrder {
+		if !claimed[*rt.ID] {
+			remoteByTitle[rt.Title] = append(remoteByTitle[rt.Title], rt)
+		}
+	}
+	for _, lt := range stillUnmatched {
+		candidates := remoteByTitle[lt.Title]
+		if localTitleCount[lt.Title] == 1 && len(candidates) == 1 {
+			matches[lt] = candidates[0]
+			claimed[*candidates[0].ID] = true
 		}
 	}
 
-	return nil
+	return matches
 }
 
-// ExportTasks Exports task lists from Google Tasks to local Markdown files.
-func ExportTasks(outputPath string, listName string, credentialsPath string) error {
+// ExportTasks Exports task lists from Google Tasks to local files. Files synced before are
+// merged and their local edits pushed to Google Tasks, following the delete policy, conflict
+// strategy and limits of opts. In dry-run mode, those pushes are printed and nothing is written.
+func ExportTasks(outputPath string, listName string, credentialsPath string, opts SyncOptions) error {
 	ctx := context.Background()
 	authClient, err := api.Authenticate(ctx, credentialsPath)
 	if err != nil {
@@ -116,77 +228,103 @@ func ExportTasks(outputPath string, listName string, credentialsPath string) err
 
 	fileInfo, err := os.Stat(outputPath)
 	isDir := err == nil && fileInfo.IsDir()
-	if isDir || !strings.HasSuffix(outputPath, ".md") {
+	extension := formatOf(opts).Extension()
+	if isDir || !strings.HasSuffix(outputPath, extension) {
 		// Directory export (many:many)
-		if err != nil && os.IsNotExist(err) {
+		if err != nil && os.IsNotExist(err) && !opts.DryRun {
 			if err := os.MkdirAll(outputPath, 0755); err != nil {
 				return fmt.Errorf("failed to create directory: %v", err)
 			}
 		}
 
+		state, err := LoadState(outputPath)
+		if err != nil {
+			return err
+		}
+		trash, err := LoadTrash(outputPath)
+		if err != nil {
+			return err
+		}
+
 		for _, rl := range remoteLists {
 			if listName != "" && rl.Title != listName {
 				continue
 			}
 
-			tasks, err := client.GetTasks(*rl.ID)
-			if err != nil {
-				return fmt.Errorf("failed to get tasks for list %s: %v", rl.Title, err)
-			}
-			rl.Tasks = tasks
+			filePath := filepath.Join(outputPath, listFileName(rl.Title, extension))
 
-			var builder strings.Builder
-			for _, c := range rl.Title {
-				if unicode.IsLetter(c) || unicode.IsDigit(c) || c == ' ' || c == '-' || c == '_' {
-					builder.WriteRune(c)
-				}
+			if err := exportTasklist(rl, filePath, client, state, trash, opts); err != nil {
+				return err
 			}
-			filename := strings.TrimRight(builder.String(), " \t\n\r")
-			if filename == "" {
-				filename = "untitled-list"
+			if !opts.DryRun {
+				fmt.Printf("Exported '%s' to %s\n", rl.Title, filePath)
 			}
-			filePath := filepath.Join(outputPath, fmt.Sprintf("%s.md", filename))
+		}
 
-			if err := markdown.SaveToFile(rl, filePath); err != nil {
-				return fmt.Errorf("failed to save to file: %v", err)
+		if !opts.DryRun {
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("failed to save sync state: %v", err)
 			}
-			fmt.Printf("Exported '%s' to %s\n", rl.Title, filePath)
 		}
 	} else {
-		// File export (1:1)
-		if listName == "" {
+		// File export (1:1, or one list per section)
+		var existing []*models.TaskList
+		if localLists, sections, err := loadFile(outputPath, opts); err == nil {
+			opts.Markdown.Sections = sections
+			existing = localLists
+		}
+		if listName == "" && !opts.Markdown.Sections {
 			return fmt.Errorf("list-name must be specified when exporting to a single file")
 		}
 
-		var targetList *models.TaskList
+		// A sections file gets the named list, the lists it already holds, or else every list
+		var targetLists []*models.TaskList
 		for _, rl := range remoteLists {
-			if rl.Title == listName {
-				targetList = rl
-				break
+			if listName != "" {
+				if rl.Title == listName {
+					targetLists = append(targetLists, rl)
+					break
+				}
+				continue
+			}
+			if len(existing) == 0 || sectionFor(existing, rl) != nil {
+				targetLists = append(targetLists, rl)
 			}
 		}
 
-		if targetList == nil {
+		if listName != "" && len(targetLists) == 0 {
 			return fmt.Errorf("task list '%s' not found on Google Tasks", listName)
 		}
 
-		tasks, err := client.GetTasks(*targetList.ID)
+		state, err := LoadState(filepath.Dir(outputPath))
 		if err != nil {
-			return fmt.Errorf("failed to get tasks: %v", err)
+			return err
+		}
+		trash, err := LoadTrash(filepath.Dir(outputPath))
+		if err != nil {
+			return err
 		}
-		targetList.Tasks = tasks
 
-		if err := markdown.SaveToFile(targetList, outputPath); err != nil {
-			return fmt.Errorf("failed to save to file: %v", err)
+		for _, targetList := range targetLists {
+			if err := exportTasklist(targetList, outputPath, client, state, trash, opts); err != nil {
+				return err
+			}
+			if !opts.DryRun {
+				fmt.Printf("Exported '%s' to %s\n", targetList.Title, outputPath)
+			}
+		}
+		if !opts.DryRun {
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("failed to save sync state: %v", err)
+			}
 		}
-		fmt.Printf("Exported '%s' to %s\n", targetList.Title, outputPath)
 	}
 
 	return nil
 }
 
-// ImportTasks Imports task lists from local Markdown files to Google Tasks.
-func ImportTasks(inputPath string, listName string, credentialsPath string) error {
+// ImportTasks Imports task lists from local files to Google Tasks.
+func ImportTasks(inputPath string, listName string, credentialsPath string, opts SyncOptions) error {
 	ctx := context.Background()
 	authClient, err := api.Authenticate(ctx, credentialsPath)
 	if err != nil {
@@ -213,6 +351,52 @@ func ImportTasks(inputPath string, listName string, credentialsPath string) erro
 		return fmt.Errorf("file or directory not found: %v", err)
 	}
 
+	stateDir := filepath.Dir(inputPath)
+	if fileInfo.IsDir() {
+		stateDir = inputPath
+	}
+	state, err := LoadState(stateDir)
+	if err != nil {
+		return err
+	}
+	opts.Trash, err = LoadTrash(stateDir)
+	if err != nil {
+		return err
+	}
+
+	// importList finds or creates the remote list for a local list and pushes the local list to it.
+	// With byID set, the list ID recorded in the file takes precedence over the title.
+	importList := func(localList *models.TaskList, targetTitle string, filePath string, byID bool) error {
+		var targetList *models.TaskList
+		if existing := remoteListByID(remoteLists, localList); existing != nil && byID {
+			targetList = existing
+			fmt.Printf("Syncing %s to existing list '%s'...\n", filePath, existing.Title)
+		} else if existing, ok := remoteListsMap[targetTitle]; ok {
+			targetList = existing
+			fmt.Printf("Syncing %s to existing list '%s'...\n", filePath, targetTitle)
+		} else if opts.DryRun {
+			targetList = &models.TaskList{Title: targetTitle}
+			remoteListsMap[targetTitle] = targetList
+			fmt.Printf("Would create new list '%s' from %s...\n", targetTitle, filePath)
+		} else {
+			created, err := client.CreateTasklist(targetTitle)
+			if err != nil {
+				return fmt.Errorf("failed to create tasklist: %v", err)
+			}
+			targetList = created
+			remoteListsMap[targetTitle] = targetList
+			fmt.Printf("Created new list '%s' and syncing from %s...\n", targetTitle, filePath)
+		}
+
+		if err := importTasklist(localList, filePath, targetList, client, state, opts); err != nil {
+			return fmt.Errorf("failed to sync tasklist: %v", err)
+		}
+		if !opts.DryRun {
+			fmt.Printf("Successfully imported '%s' from %s\n", targetList.Title, filePath)
+		}
+		return nil
+	}
+
 	if fileInfo.IsDir() {
 		// Directory import (many:many)
 		entries, err := os.ReadDir(inputPath)
@@ -221,71 +405,416 @@ func ImportTasks(inputPath string, listName string, credentialsPath string) erro
 		}
 
 		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), formatOf(opts).Extension()) {
 				filePath := filepath.Join(inputPath, entry.Name())
-				localList, err := markdown.LoadFromFile(filePath)
+				localLists, _, err := loadTasklists(filePath, opts)
 				if err != nil {
 					return fmt.Errorf("failed to load from file: %v", err)
 				}
 
-				targetTitle := localList.Title
-				var targetList *models.TaskList
-
-				if existing, ok := remoteListsMap[targetTitle]; ok {
-					targetList = existing
-					fmt.Printf("Syncing %s to existing list '%s'...\n", filePath, targetTitle)
-				} else {
-					created, err := client.CreateTasklist(targetTitle)
-					if err != nil {
-						return fmt.Errorf("failed to create tasklist: %v", err)
+				for _, localList := range localLists {
+					if err := importList(localList, localList.Title, filePath, true); err != nil {
+						return err
 					}
-					targetList = created
-					remoteListsMap[targetTitle] = targetList
-					fmt.Printf("Created new list '%s' and syncing from %s...\n", targetTitle, filePath)
 				}
-
-				if err := SyncTasklist(localList, *targetList.ID, client); err != nil {
-					return fmt.Errorf("failed to sync tasklist: %v", err)
-				}
-				fmt.Printf("Successfully imported %s\n", filePath)
 			}
 		}
 	} else {
-		// File import (1:1)
-		localList, err := markdown.LoadFromFile(inputPath)
+		// File import (1:1, or one list per section)
+		localLists, sections, err := loadTasklists(inputPath, opts)
 		if err != nil {
 			return fmt.Errorf("failed to load from file: %v", err)
 		}
 
-		targetTitle := localList.Title
-		if listName != "" {
-			targetTitle = listName
+		for _, localList := range localLists {
+			targetTitle := localList.Title
+			if sections {
+				// Sections name their own lists, so the list name selects one of them
+				if listName != "" && localList.Title != listName {
+					continue
+				}
+			} else {
+				if listName != "" {
+					targetTitle = listName
+				}
+				if targetTitle == "" || targetTitle == "Untitled List" {
+					base := filepath.Base(inputPath)
+					targetTitle = strings.TrimSuffix(base, filepath.Ext(base))
+				}
+			}
+
+			if err := importList(localList, targetTitle, inputPath, sections || listName == ""); err != nil {
+				return err
+			}
 		}
+	}
+
+	if opts.DryRun {
+		return nil
+	}
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("failed to save sync state: %v", err)
+	}
+
+	return nil
+}
+
+// SyncTasks synchronizes Google Tasks and local files in both directions.
+func SyncTasks(path string, listName string, credentialsPath string, opts SyncOptions) error {
+	ctx := context.Background()
+	authClient, err := api.Authenticate(ctx, credentialsPath)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %v", err)
+	}
 
-		if targetTitle == "" || targetTitle == "Untitled List" {
-			base := filepath.Base(inputPath)
-			targetTitle = strings.TrimSuffix(base, filepath.Ext(base))
+	client, err := api.NewClient(ctx, authClient)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+
+	remoteLists, err := client.GetTasklists()
+	if err != nil {
+		return fmt.Errorf("failed to get tasklists: %v", err)
+	}
+
+	total := &MergeSummary{}
+	reconcile := func(localList *models.TaskList, filePath string, remoteList *models.TaskList, state *State) error {
+		summary, err := ReconcileTasklist(localList, filePath, remoteList, client, state, opts)
+		if err != nil {
+			return fmt.Errorf("failed to sync list '%s': %v", remoteList.Title, err)
 		}
+		total.Add(summary)
+		fmt.Printf("Synced '%s' with %s: %s\n", remoteList.Title, filePath, summary)
+		return nil
+	}
 
-		var targetList *models.TaskList
-		if existing, ok := remoteListsMap[targetTitle]; ok {
-			targetList = existing
-			fmt.Printf("Syncing %s to existing list '%s'...\n", inputPath, targetTitle)
-		} else {
-			created, err := client.CreateTasklist(targetTitle)
+	fileInfo, err := os.Stat(path)
+	isDir := err == nil && fileInfo.IsDir()
+	extension := formatOf(opts).Extension()
+	if isDir || !strings.HasSuffix(path, extension) {
+		// Directory sync (many:many)
+		if err != nil && os.IsNotExist(err) {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %v", err)
+			}
+		}
+
+		state, err := LoadState(path)
+		if err != nil {
+			return err
+		}
+		opts.Trash, err = LoadTrash(path)
+		if err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %v", err)
+		}
+
+		// A file may hold several lists as sections, so local lists are tracked individually
+		var localOrder []*models.TaskList
+		localPaths := make(map[*models.TaskList]string)
+		localByTitle := make(map[string]*models.TaskList)
+		localByID := make(map[string]*models.TaskList)
+		localByPath := make(map[string]*models.TaskList)
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), extension) {
+				continue
+			}
+			filePath := filepath.Join(path, entry.Name())
+			localLists, sections, err := loadTasklists(filePath, opts)
+			if err != nil {
+				return fmt.Errorf("failed to load from file: %v", err)
+			}
+			for _, localList := range localLists {
+				localOrder = append(localOrder, localList)
+				localPaths[localList] = filePath
+				if _, exists := localByTitle[localList.Title]; !exists {
+					localByTitle[localList.Title] = localList
+				}
+				if localList.ID != nil {
+					if _, exists := localByID[*localList.ID]; !exists {
+						localByID[*localList.ID] = localList
+					}
+				}
+			}
+			if !sections {
+				localByPath[filePath] = localLists[0]
+			}
+		}
+
+		// Sync every remote list with its file, pulling lists that have no file yet
+		used := make(map[*models.TaskList]bool)
+		for _, rl := range remoteLists {
+			if listName != "" && rl.Title != listName {
+				continue
+			}
+
+			localList := localByID[*rl.ID]
+			if listState, ok := state.Lists[*rl.ID]; ok && localList == nil {
+				localList = localByPath[filepath.Join(path, listState.File)]
+			}
+			if localList == nil {
+				if candidate, ok := localByTitle[rl.Title]; ok && !used[candidate] {
+					localList = candidate
+				}
+			}
+
+			filePath := localPaths[localList]
+			if localList == nil {
+				filePath = filepath.Join(path, listFileName(rl.Title, extension))
+				localList = &models.TaskList{Title: rl.Title}
+			}
+			used[localList] = true
+
+			if err := reconcile(localList, filePath, rl, state); err != nil {
+				return err
+			}
+		}
+
+		// Push lists that have no remote list yet
+		for _, localList := range localOrder {
+			if used[localList] || (listName != "" && localList.Title != listName) {
+				continue
+			}
+			filePath := localPaths[localList]
+
+			created, err := client.CreateTasklist(localList.Title)
 			if err != nil {
 				return fmt.Errorf("failed to create tasklist: %v", err)
 			}
-			targetList = created
-			remoteListsMap[targetTitle] = targetList
-			fmt.Printf("Created new list '%s' and syncing from %s...\n", targetTitle, inputPath)
+			fmt.Printf("Created new list '%s' from %s\n", localList.Title, filePath)
+
+			if err := reconcile(localList, filePath, created, state); err != nil {
+				return err
+			}
 		}
 
-		if err := SyncTasklist(localList, *targetList.ID, client); err != nil {
-			return fmt.Errorf("failed to sync tasklist: %v", err)
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("failed to save sync state: %v", err)
+		}
+	} else {
+		// File sync (1:1, or one list per section)
+		state, err := LoadState(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		opts.Trash, err = LoadTrash(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		localLists := []*models.TaskList{{Title: listName}}
+		sections := opts.Markdown.Sections
+		if fileInfo != nil {
+			localLists, sections, err = loadTasklists(path, opts)
+			if err != nil {
+				return fmt.Errorf("failed to load from file: %v", err)
+			}
+		}
+
+		for _, localList := range localLists {
+			targetTitle := localList.Title
+			if sections {
+				// Sections name their own lists, so the list name selects one of them
+				if listName != "" && localList.Title != listName {
+					continue
+				}
+			} else if listName != "" {
+				targetTitle = listName
+			}
+			if targetTitle == "" || targetTitle == "Untitled List" {
+				base := filepath.Base(path)
+				targetTitle = strings.TrimSuffix(base, filepath.Ext(base))
+			}
+
+			var targetList *models.TaskList
+			if sections || listName == "" {
+				targetList = remoteListByID(remoteLists, localList)
+			}
+			for _, rl := range remoteLists {
+				if targetList == nil && rl.Title == targetTitle {
+					targetList = rl
+					break
+				}
+			}
+
+			if targetList == nil {
+				created, err := client.CreateTasklist(targetTitle)
+				if err != nil {
+					return fmt.Errorf("failed to create tasklist: %v", err)
+				}
+				targetList = created
+				fmt.Printf("Created new list '%s' from %s\n", targetTitle, path)
+			}
+
+			if err := reconcile(localList, path, targetList, state); err != nil {
+				return err
+			}
+		}
+
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("failed to save sync state: %v", err)
+		}
+	}
+
+	fmt.Printf("Sync complete: %s\n", total)
+	return nil
+}
+
+// importTasklist pushes a local list to Google Tasks. When the list was synced before,
+// remote edits made since are merged in instead of being overwritten. In dry-run mode
+// the planned operations are printed instead.
+func importTasklist(localList *models.TaskList, filePath string, remoteList *models.TaskList, client *api.GoogleTasksClient, state *State, opts SyncOptions) error {
+	if opts.DryRun {
+		return printPlan(localList, filePath, remoteList, client, state, opts)
+	}
+
+	if _, synced := state.Lists[*remoteList.ID]; synced {
+		_, err := ReconcileTasklist(localList, filePath, remoteList, client, state, opts)
+		return err
+	}
+
+	if err := SyncTasklist(localList, filePath, *remoteList.ID, client, opts); err != nil {
+		return err
+	}
+	state.Record(*remoteList.ID, remoteList.Title, filePath, localList.Tasks)
+	return nil
+}
+
+// printPlan prints the operations that pushing a local list to Google Tasks would apply,
+// merging in the remote edits made since the last sync when the list was synced before.
+func printPlan(localList *models.TaskList, filePath string, remoteList *models.TaskList, client *api.GoogleTasksClient, state *State, opts SyncOptions) error {
+	var remoteTasks []*models.Task
+	if remoteList.ID != nil {
+		tasks, err := client.GetTasks(*remoteList.ID)
+		if err != nil {
+			return err
+		}
+		remoteTasks = tasks
+		if _, synced := state.Lists[*remoteList.ID]; synced {
+			localList, _ = mergeLocalFile(localList, filePath, remoteTasks, state.Lists[*remoteList.ID], opts)
+		}
+	}
+
+	return withDepthLimit(localList, opts.Nesting, func() error {
+		plan := PlanTasklist(localList, remoteTasks, opts.Delete, formatOf(opts).Capabilities())
+		plan.ListName = remoteList.Title
+		fmt.Print(plan)
+		if err := checkDeletions(plan, filePath, remoteTasks, opts); err != nil {
+			fmt.Printf("  Warning: %v\n", err)
+		}
+		return nil
+	})
+}
+
+// exportTasklist writes a remote list to filePath. When the list was synced before and the file
+// still exists, local edits made since are merged in and pushed to Google Tasks instead of being
+// overwritten, as by sync. In dry-run mode, those pushes are printed and nothing is written.
+func exportTasklist(remoteList *models.TaskList, filePath string, client *api.GoogleTasksClient, state *State, trash *Trash, opts SyncOptions) error {
+	opts.Trash = trash
+	if _, synced := state.Lists[*remoteList.ID]; synced {
+		if localList, err := loadTasklistFor(filePath, remoteList, opts); err == nil && localList != nil {
+			if opts.DryRun {
+				return printPlan(localList, filePath, remoteList, client, state, opts)
+			}
+			_, err := ReconcileTasklist(localList, filePath, remoteList, client, state, opts)
+			return err
 		}
-		fmt.Printf("Successfully imported %s\n", inputPath)
+	}
+	if opts.DryRun {
+		fmt.Printf("Would write '%s' to %s\n", remoteList.Title, filePath)
+		return nil
+	}
+
+	tasks, err := client.GetTasks(*remoteList.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks for list %s: %v", remoteList.Title, err)
+	}
+	remoteList.Tasks = tasks
+	now := time.Now().UTC()
+	remoteList.SyncedAt = &now
+	if localLists, sections, err := loadFile(filePath, opts); err == nil {
+		existing := localLists[0]
+		if sections {
+			existing = sectionFor(localLists, remoteList)
+		}
+		if existing != nil {
+			remoteList.Metadata = existing.Metadata
+			remoteList.FrontMatter = existing.FrontMatter
+			remoteList.Content = carryContent(existing, remoteList)
+		}
+	}
+
+	if err := saveFile(remoteList, filePath, opts); err != nil {
+		return fmt.Errorf("failed to save to file: %v", err)
+	}
+	state.Record(*remoteList.ID, remoteList.Title, filePath, tasks)
+	return nil
+}
+
+// carryContent anchors the free-form content of a list loaded from a file to the top-level
+// tasks of another version of that list, matched by ID and then by title. Blocks whose task
+// is gone follow the closest preceding task that is still there.
+func carryContent(from *models.TaskList, to *models.TaskList) []*models.ContentBlock {
+	find := func(task *models.Task) *models.Task {
+		for _, candidate := range to.Tasks {
+			if candidate == task || (task.ID != nil && candidate.ID != nil && *candidate.ID == *task.ID) {
+				return candidate
+			}
+		}
+		for _, candidate := range to.Tasks {
+			if candidate.Title == task.Title {
+				return candidate
+			}
+		}
+		return nil
+	}
+
+	index := make(map[*models.Task]int)
+	for i, task := range from.Tasks {
+		index[task] = i
 	}
 
+	var content []*models.ContentBlock
+	for _, block := range from.Content {
+		carried := &models.ContentBlock{Text: block.Text, BeforeTitle: block.BeforeTitle}
+		if i, ok := index[block.After]; ok {
+			for ; i >= 0 && carried.After == nil; i-- {
+				carried.After = find(from.Tasks[i])
+			}
+		}
+		content = append(content, carried)
+	}
+	return content
+}
+
+// remoteListByID returns the remote list whose ID is recorded in the front matter of a local list, if any.
+func remoteListByID(remoteLists []*models.TaskList, localList *models.TaskList) *models.TaskList {
+	if localList.ID == nil {
+		return nil
+	}
+	for _, rl := range remoteLists {
+		if rl.ID != nil && *rl.ID == *localList.ID {
+			return rl
+		}
+	}
 	return nil
 }
+
+// listFileName derives a file name with the given extension from a task list title.
+func listFileName(title string, extension string) string {
+	var builder strings.Builder
+	for _, c := range title {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == ' ' || c == '-' || c == '_' {
+			builder.WriteRune(c)
+		}
+	}
+	filename := strings.TrimRight(builder.String(), " \t\n\r")
+	if filename == "" {
+		filename = "untitled-list"
+	}
+	return filename + extension
+}
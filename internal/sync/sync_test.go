@@ -0,0 +1,530 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gtasks2md/internal/api"
+	"gtasks2md/internal/format"
+	"gtasks2md/internal/models"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestMatchTasksByIDThenTitle(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("r1"), Title: "Old name", Status: "needsAction"},
+		{ID: strPtr("r2"), Title: "Unchanged", Status: "needsAction"},
+		{ID: strPtr("r3"), Title: "Removed", Status: "needsAction"},
+	}
+	localTasks := []*models.Task{
+		{ID: strPtr("r1"), Title: "New name", Status: "needsAction"},
+		{Title: "Unchanged", Status: "completed"},
+		{Title: "Brand new", Status: "needsAction"},
+	}
+
+	matches := matchTasks(localTasks, remoteTasks)
+
+	if rt := matches[localTasks[0]]; rt == nil || *rt.ID != "r1" {
+		t.Errorf("Expected renamed task to match r1 by ID, got %+v", rt)
+	}
+	if rt := matches[localTasks[1]]; rt == nil || *rt.ID != "r2" {
+		t.Errorf("Expected task without ID to match r2 by title, got %+v", rt)
+	}
+	if rt, ok := matches[localTasks[2]]; ok {
+		t.Errorf("Expected new task to be unmatched, got %+v", rt)
+	}
+	if len(matches) != 2 {
+		t.Errorf("Expected 2 matches, got %d", len(matches))
+	}
+}
+
+func TestPlanTasklist(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("r1"), Title: "Write report", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("r2"), Title: "Collect data", Status: "needsAction"},
+		}},
+		{ID: strPtr("r3"), Title: "Old idea", Status: "needsAction"},
+	}
+	localList := &models.TaskList{
+		Title: "Work",
+		Tasks: []*models.Task{
+			{ID: strPtr("r1"), Title: "Write report", Status: "completed", Children: []*models.Task{
+				{Title: "Draft outline", Status: "needsAction"},
+			}},
+		},
+	}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.FullCapabilities)
+
+	expected := []string{
+		"delete 'Collect data'",
+		"delete 'Old idea'",
+		"update 'Write report' (status)",
+		"create 'Draft outline' under 'Write report'",
+	}
+	if len(plan.Operations) != len(expected) {
+		t.Fatalf("Expected %d operations, got %d:\n%s", len(expected), len(plan.Operations), plan)
+	}
+	for i, op := range plan.Operations {
+		if op.String() != expected[i] {
+			t.Errorf("Operation %d: expected \"%s\", got \"%s\"", i, expected[i], op.String())
+		}
+	}
+}
+
+func TestPlanTasklistOrdering(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "B", Status: "needsAction"},
+		{ID: strPtr("c"), Title: "C", Status: "needsAction"},
+	}
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("c"), Title: "C", Status: "needsAction"},
+		{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+		{Title: "D", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "B", Status: "needsAction"},
+	}}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.FullCapabilities)
+
+	var ops []string
+	for _, op := range plan.Operations {
+		ops = append(ops, op.String())
+	}
+	expected := []string{
+		"move 'C' to the start of the top level",
+		"create 'D'",
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected operations %v, got %v", expected, ops)
+	}
+	for i := range expected {
+		if ops[i] != expected[i] {
+			t.Errorf("Operation %d: expected \"%s\", got \"%s\"", i, expected[i], ops[i])
+		}
+	}
+	if plan.Operations[1].Previous != localList.Tasks[1] {
+		t.Errorf("Expected 'D' to be created after 'A'")
+	}
+}
+
+func TestPlanTasklistReparenting(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "A", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("a1"), Title: "A1", Status: "needsAction"},
+		}},
+		{ID: strPtr("b"), Title: "B", Status: "needsAction"},
+		{ID: strPtr("c"), Title: "C", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("c1"), Title: "C1", Status: "needsAction"},
+		}},
+	}
+	// A is demoted under B, its subtask A1 is promoted, and C is deleted while C1 moves under B.
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a1"), Title: "A1", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "B", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+			{ID: strPtr("c1"), Title: "C1", Status: "needsAction"},
+		}},
+	}}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.FullCapabilities)
+
+	var ops []string
+	for _, op := range plan.Operations {
+		ops = append(ops, op.String())
+	}
+	expected := []string{
+		"move 'A1' to the start of the top level",
+		"move 'C1' to the start of the top level",
+		"delete 'C'",
+		"move 'A' to the start of 'B'",
+		"move 'C1' to 'B' after 'A'",
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected operations %v, got %v", expected, ops)
+	}
+	for i := range expected {
+		if ops[i] != expected[i] {
+			t.Errorf("Operation %d: expected \"%s\", got \"%s\"", i, expected[i], ops[i])
+		}
+	}
+}
+
+func TestMatchTasksDuplicateTitles(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("m1"), Title: "Monday meeting", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("m1n"), Title: "Send notes", Status: "needsAction"},
+		}},
+		{ID: strPtr("m2"), Title: "Tuesday meeting", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("m2n"), Title: "Send notes", Status: "needsAction"},
+		}},
+		{ID: strPtr("c1"), Title: "Call back", Status: "needsAction"},
+		{ID: strPtr("c2"), Title: "Call back", Status: "needsAction"},
+		{ID: strPtr("x"), Title: "Moved", Status: "needsAction"},
+	}
+	localTasks := []*models.Task{
+		{Title: "Monday meeting", Status: "needsAction", Children: []*models.Task{
+			{Title: "Send notes", Status: "completed"},
+		}},
+		{Title: "Tuesday meeting", Status: "needsAction", Children: []*models.Task{
+			{Title: "Send notes", Status: "needsAction"},
+			{Title: "Moved", Status: "needsAction"},
+		}},
+		{Title: "Call back", Status: "completed"},
+		{Title: "Call back", Status: "needsAction"},
+	}
+
+	matches := matchTasks(localTasks, remoteTasks)
+
+	expected := map[*models.Task]string{
+		localTasks[0]:             "m1",
+		localTasks[0].Children[0]: "m1n",
+		localTasks[1]:             "m2",
+		localTasks[1].Children[0]: "m2n",
+		localTasks[1].Children[1]: "x",
+		localTasks[2]:             "c1",
+		localTasks[3]:             "c2",
+	}
+	for lt, id := range expected {
+		if rt := matches[lt]; rt == nil || *rt.ID != id {
+			t.Errorf("Expected '%s' to match %s, got %+v", lt.Title, id, rt)
+		}
+	}
+	if len(matches) != len(expected) {
+		t.Errorf("Expected %d matches, got %d", len(expected), len(matches))
+	}
+}
+
+func TestPlanTasklistDeletePolicy(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Done", Status: "completed"},
+		{ID: strPtr("b"), Title: "Open", Status: "needsAction"},
+	}
+	localList := &models.TaskList{}
+
+	tests := []struct {
+		policy   DeletePolicy
+		expected []string
+	}{
+		{DeleteNever, nil},
+		{DeleteCompleted, []string{"delete 'Done'"}},
+		{DeleteAlways, []string{"delete 'Done'", "delete 'Open'"}},
+	}
+
+	for _, tt := range tests {
+		plan := PlanTasklist(localList, remoteTasks, tt.policy, format.FullCapabilities)
+		if len(plan.Operations) != len(tt.expected) {
+			t.Errorf("%s: expected %d operations, got %d:\n%s", tt.policy, len(tt.expected), len(plan.Operations), plan)
+			continue
+		}
+		for i, op := range plan.Operations {
+			if op.String() != tt.expected[i] {
+				t.Errorf("%s: expected \"%s\", got \"%s\"", tt.policy, tt.expected[i], op.String())
+			}
+		}
+	}
+}
+
+func TestPlanTasklistDeletePolicyKeepsSubtasks(t *testing.T) {
+	// A completed parent is missing locally along with its open subtask, which the
+	// policy protects. Deleting the parent would delete the subtask with it.
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Done", Status: "completed", Children: []*models.Task{
+			{ID: strPtr("a1"), Title: "Open", Status: "needsAction"},
+			{ID: strPtr("a2"), Title: "Also done", Status: "completed"},
+		}},
+		{ID: strPtr("b"), Title: "Finished", Status: "completed", Children: []*models.Task{
+			{ID: strPtr("b1"), Title: "Finished too", Status: "completed"},
+		}},
+	}
+
+	plan := PlanTasklist(&models.TaskList{}, remoteTasks, DeleteCompleted, format.FullCapabilities)
+
+	var ops []string
+	for _, op := range plan.Operations {
+		ops = append(ops, op.String())
+	}
+	expected := []string{"delete 'Also done'", "delete 'Finished too'", "delete 'Finished'"}
+	if strings.Join(ops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected operations %v, got %v", expected, ops)
+	}
+}
+
+func TestCheckDeletions(t *testing.T) {
+	var remoteTasks []*models.Task
+	for i := 0; i < 10; i++ {
+		remoteTasks = append(remoteTasks, &models.Task{ID: strPtr(string(rune('a' + i))), Title: string(rune('A' + i)), Status: "needsAction"})
+	}
+	opts := SyncOptions{MaxDeletePercent: 50, MaxDeleteCount: 25}
+
+	// A Markdown file that failed to parse yields no tasks at all
+	plan := PlanTasklist(&models.TaskList{Title: "Work"}, remoteTasks, DeleteAlways, format.FullCapabilities)
+	err := checkDeletions(plan, "work.md", remoteTasks, opts)
+	if err == nil {
+		t.Fatalf("Expected deleting every task to be refused")
+	}
+	if !strings.Contains(err.Error(), "10 of 10 tasks from list 'Work'") || !strings.Contains(err.Error(), "'J'") || !strings.Contains(err.Error(), "check work.md (markdown format)") {
+		t.Errorf("Expected the error to list what would be removed, got: %v", err)
+	}
+
+	opts.Force = true
+	if err := checkDeletions(plan, "work.md", remoteTasks, opts); err != nil {
+		t.Errorf("Expected --force to override the limit, got: %v", err)
+	}
+
+	// Deleting a few tasks stays within the limits
+	plan = PlanTasklist(&models.TaskList{Title: "Work", Tasks: remoteTasks[:7]}, remoteTasks, DeleteAlways, format.FullCapabilities)
+	if err := checkDeletions(plan, "work.md", remoteTasks, SyncOptions{MaxDeletePercent: 50, MaxDeleteCount: 25}); err != nil {
+		t.Errorf("Expected deleting 3 of 10 tasks to be allowed, got: %v", err)
+	}
+}
+
+// redirectTransport sends every request to a test server.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme, req.URL.Host = r.target.Scheme, r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestExecutePlanSavesTrash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("Expected only deletions, got %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	client, err := api.NewClient(context.Background(), &http.Client{Transport: redirectTransport{target}})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	trash, err := LoadTrash(dir)
+	if err != nil {
+		t.Fatalf("LoadTrash failed: %v", err)
+	}
+	opts := SyncOptions{MaxDeletePercent: 50, Trash: trash}
+
+	// The first list deletes a task
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Keep", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "Drop", Status: "needsAction"},
+	}
+	plan := PlanTasklist(&models.TaskList{Title: "Home", Tasks: remoteTasks[:1]}, remoteTasks, DeleteAlways, format.FullCapabilities)
+	if err := ExecutePlan(plan, "home", client, opts.Trash); err != nil {
+		t.Fatalf("ExecutePlan failed: %v", err)
+	}
+
+	// The next list fails, ending the command before it gets to save anything
+	otherTasks := []*models.Task{
+		{ID: strPtr("c"), Title: "One", Status: "needsAction"},
+		{ID: strPtr("d"), Title: "Two", Status: "needsAction"},
+	}
+	plan = PlanTasklist(&models.TaskList{Title: "Work"}, otherTasks, DeleteAlways, format.FullCapabilities)
+	if err := checkDeletions(plan, "work.md", otherTasks, opts); err == nil {
+		t.Fatalf("Expected the second list to fail")
+	}
+
+	saved, err := LoadTrash(dir)
+	if err != nil {
+		t.Fatalf("LoadTrash failed: %v", err)
+	}
+	if len(saved.Tasks) != 1 || saved.Tasks[0].Title != "Drop" || saved.Tasks[0].ListID != "home" {
+		t.Errorf("Expected the deleted task to be in the saved trash, got %+v", saved.Tasks)
+	}
+}
+
+func TestPlanTasklistKeepsUnsupportedFields(t *testing.T) {
+	notes := "Milk, Eggs"
+	due, _ := models.ParseDate("2026-10-20")
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Groceries", Status: "needsAction", Notes: &notes, Due: due},
+		{ID: strPtr("b"), Title: "Call back", Status: "needsAction", Notes: &notes},
+		{ID: strPtr("c"), Title: "Pay  rent\nby Friday", Status: "needsAction"},
+	}
+	// A file format without notes, due dates or title spacing loses them, and one task was renamed
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Groceries", Status: "needsAction"},
+		{ID: strPtr("b"), Title: "Call back today", Status: "needsAction"},
+		{ID: strPtr("c"), Title: "Pay rent by Friday", Status: "needsAction"},
+	}}
+	caps := format.Capabilities{TaskIDs: true, Subtasks: true}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, caps)
+
+	if len(plan.Operations) != 1 || plan.Operations[0].String() != "update 'Call back' (title)" {
+		t.Fatalf("Expected only the title to be updated, got:\n%s", plan)
+	}
+	if task := plan.Operations[0].Task; task.Notes == nil || *task.Notes != notes {
+		t.Errorf("Expected the update to keep the remote notes, got %v", task.Notes)
+	}
+	if localList.Tasks[0].DueDate() != "2026-10-20" {
+		t.Errorf("Expected the remote due date to be kept, got '%s'", localList.Tasks[0].DueDate())
+	}
+}
+
+func TestPlanTasklistIgnoresOrgSpacing(t *testing.T) {
+	remoteNotes := "\nMilk\n  indented\n\n"
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Buy  groceries\n", Status: "needsAction", Notes: &remoteNotes},
+	}
+	// Org writes titles on a single line and drops the blank lines around notes
+	localNotes := "Milk\n  indented"
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Buy groceries", Status: "needsAction", Notes: &localNotes},
+	}}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.Org{}.Capabilities())
+
+	if len(plan.Operations) != 0 {
+		t.Errorf("Expected no operations for a list that only lost its spacing, got:\n%s", plan)
+	}
+}
+
+func TestPlanTasklistSkipsUnchangedTasks(t *testing.T) {
+	notes := "Milk, Eggs"
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "Buy groceries", Status: "needsAction", Notes: &notes, Children: []*models.Task{
+			{ID: strPtr("b"), Title: "Pay at checkout", Status: "completed"},
+		}},
+	}
+	sameNotes := "Milk, Eggs"
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Buy groceries", Status: "needsAction", Notes: &sameNotes, Children: []*models.Task{
+			{ID: strPtr("b"), Title: "Pay at checkout", Status: "completed"},
+		}},
+	}}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.FullCapabilities)
+
+	if len(plan.Operations) != 0 {
+		t.Errorf("Expected no operations for an unchanged list, got:\n%s", plan)
+	}
+}
+
+func TestPlanWaves(t *testing.T) {
+	remoteTasks := []*models.Task{
+		{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+		{ID: strPtr("x"), Title: "X", Status: "needsAction", Children: []*models.Task{
+			{ID: strPtr("x1"), Title: "X1", Status: "needsAction"},
+		}},
+	}
+	localList := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "A", Status: "needsAction"},
+		{Title: "D", Status: "needsAction"},
+		{Title: "E", Status: "needsAction"},
+		{Title: "F", Status: "needsAction", Children: []*models.Task{
+			{Title: "F1", Status: "needsAction"},
+		}},
+	}}
+
+	plan := PlanTasklist(localList, remoteTasks, DeleteAlways, format.FullCapabilities)
+
+	var waves [][]string
+	for _, wave := range planWaves(plan.Operations) {
+		var ops []string
+		for _, entry := range wave {
+			desc := entry.op.String()
+			if entry.op.Kind == OperationCreate && entry.previous != nil {
+				desc += " after '" + entry.previous.Title + "'"
+			}
+			ops = append(ops, desc)
+		}
+		waves = append(waves, ops)
+	}
+
+	expected := [][]string{
+		{"delete 'X1'"},
+		{"delete 'X'", "create 'F' after 'A'", "create 'E' after 'A'", "create 'D' after 'A'"},
+		{"create 'F1' under 'F'"},
+	}
+	if len(waves) != len(expected) {
+		t.Fatalf("Expected waves %v, got %v", expected, waves)
+	}
+	for i := range expected {
+		if strings.Join(waves[i], "; ") != strings.Join(expected[i], "; ") {
+			t.Errorf("Wave %d: expected %v, got %v", i, expected[i], waves[i])
+		}
+	}
+}
+
+func TestLimitDepth(t *testing.T) {
+	newList := func() *models.TaskList {
+		return &models.TaskList{Tasks: []*models.Task{
+			{Title: "A", Children: []*models.Task{
+				{Title: "A1", Children: []*models.Task{
+					{Title: "A1a", Children: []*models.Task{
+						{Title: "A1a-i"},
+					}},
+					{Title: "A1b"},
+				}},
+				{Title: "A2"},
+			}},
+		}}
+	}
+
+	list := newList()
+	flattened, err := limitDepth(list, NestingFlatten)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if flattened != 3 {
+		t.Errorf("Expected 3 tasks to be flattened, got %d", flattened)
+	}
+	var titles []string
+	for _, child := range list.Tasks[0].Children {
+		titles = append(titles, child.Title)
+		if len(child.Children) > 0 {
+			t.Errorf("Expected '%s' to have no subtasks", child.Title)
+		}
+	}
+	if strings.Join(titles, ", ") != "A1, A1a, A1a-i, A1b, A2" {
+		t.Errorf("Unexpected subtasks after flattening: %v", titles)
+	}
+
+	if _, err := limitDepth(newList(), NestingReject); err == nil || !strings.Contains(err.Error(), "'A1a'") {
+		t.Errorf("Expected an error naming 'A1a', got %v", err)
+	}
+}
+
+func TestCarryContent(t *testing.T) {
+	local := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Freeze the branch"},
+		{Title: "Write notes"},
+		{ID: strPtr("c"), Title: "Old step"},
+	}}
+	local.Content = []*models.ContentBlock{
+		{Text: "Intro"},
+		{Text: "After notes", After: local.Tasks[1]},
+		{Text: "After old step", After: local.Tasks[2]},
+	}
+
+	merged := &models.TaskList{Tasks: []*models.Task{
+		{ID: strPtr("a"), Title: "Freeze the branch"},
+		{ID: strPtr("b"), Title: "Write notes"},
+	}}
+
+	content := carryContent(local, merged)
+
+	expected := []*models.Task{nil, merged.Tasks[1], merged.Tasks[1]}
+	if len(content) != len(expected) {
+		t.Fatalf("Expected %d blocks, got %d", len(expected), len(content))
+	}
+	for i, block := range content {
+		if block.After != expected[i] {
+			t.Errorf("Block '%s': expected to follow %v, got %v", block.Text, expected[i], block.After)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gtasks2md/internal/api"
+	"gtasks2md/internal/models"
+)
+
+const trashFileName = "trash.json"
+
+// TrashedTask is a remote task removed by an import, kept so that it can be restored.
+type TrashedTask struct {
+	ListID    string    `json:"list_id"`
+	ListTitle string    `json:"list_title"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Notes     *string   `json:"notes,omitempty"`
+	Due       string    `json:"due,omitempty"`
+	Parent    string    `json:"parent,omitempty"`
+}
+
+// Trash holds the tasks deleted from Google Tasks by imports from a directory.
+type Trash struct {
+	Tasks []*TrashedTask `json:"tasks"`
+
+	path string
+}
+
+// LoadTrash loads the trash of the Markdown files in dir. A missing trash file yields an empty trash.
+func LoadTrash(dir string) (*Trash, error) {
+	trash := &Trash{path: filepath.Join(dir, stateDirName, trashFileName)}
+
+	data, err := os.ReadFile(trash.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return trash, nil
+		}
+		return nil, fmt.Errorf("unable to read trash: %v", err)
+	}
+
+	if err := json.Unmarshal(data, trash); err != nil {
+		return nil, fmt.Errorf("unable to parse trash %s: %v", trash.path, err)
+	}
+	return trash, nil
+}
+
+// Save writes the trash back to disk.
+func (t *Trash) Save() error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("unable to create trash directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode trash: %v", err)
+	}
+	return os.WriteFile(t.path, append(data, '\n'), 0644)
+}
+
+// Add records a remote task that has just been deleted from the given list.
+func (t *Trash) Add(listID string, listTitle string, task *models.Task) {
+	trashed := &TrashedTask{
+		ListID:    listID,
+		ListTitle: listTitle,
+		DeletedAt: time.Now().UTC(),
+		ID:        *task.ID,
+		Title:     task.Title,
+		Status:    task.Status,
+		Notes:     task.Notes,
+		Due:       task.DueDate(),
+	}
+	if task.Parent != nil {
+		trashed.Parent = *task.Parent
+	}
+	t.Tasks = append(t.Tasks, trashed)
+}
+
+// RestoreTasks recreates trashed tasks in Google Tasks and removes them from the trash.
+// Only tasks from the list named listName and with the given title are restored, when set.
+// With listOnly, the matching tasks are printed instead.
+func RestoreTasks(path string, listName string, title string, credentialsPath string, listOnly bool) error {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	trash, err := LoadTrash(dir)
+	if err != nil {
+		return err
+	}
+
+	var selected, remaining []*TrashedTask
+	for _, tt := range trash.Tasks {
+		if (listName == "" || tt.ListTitle == listName) && (title == "" || tt.Title == title) {
+			selected = append(selected, tt)
+		} else {
+			remaining = append(remaining, tt)
+		}
+	}
+
+	if len(selected) == 0 {
+		fmt.Println("No matching tasks in the trash.")
+		return nil
+	}
+
+	if listOnly {
+		for _, tt := range selected {
+			fmt.Printf("%s  '%s' from list '%s' (%s)\n", tt.DeletedAt.Local().Format("2006-01-02 15:04"), tt.Title, tt.ListTitle, tt.Status)
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	authClient, err := api.Authenticate(ctx, credentialsPath)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %v", err)
+	}
+
+	client, err := api.NewClient(ctx, authClient)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+
+	// Restore parents before their children, so that children can be reattached to the
+	// recreated parent. Children of parents that still exist are reattached to them directly.
+	restoredIDs := make(map[string]string)
+	pending := selected
+	for len(pending) > 0 {
+		var next []*TrashedTask
+		for _, tt := range pending {
+			parentID := tt.Parent
+			if newID, ok := restoredIDs[parentID]; ok {
+				parentID = newID
+			} else if parentID != "" && waitingFor(pending, parentID) {
+				next = append(next, tt)
+				continue
+			}
+
+			due, _ := models.ParseDate(tt.Due)
+			task := &models.Task{
+				Title:  tt.Title,
+				Status: tt.Status,
+				Notes:  tt.Notes,
+				Due:    due,
+			}
+			created, err := client.CreateTask(tt.ListID, task, parentID, "")
+			if err != nil && parentID != "" {
+				// The parent no longer exists; restore the task at the top level instead
+				created, err = client.CreateTask(tt.ListID, task, "", "")
+			}
+			if err != nil {
+				remaining = append(remaining, tt)
+				fmt.Fprintf(os.Stderr, "Warning: failed to restore task '%s': %v\n", tt.Title, err)
+				continue
+			}
+
+			restoredIDs[tt.ID] = *created.ID
+			fmt.Printf("Restored '%s' to list '%s'\n", tt.Title, tt.ListTitle)
+		}
+		pending = next
+	}
+
+	trash.Tasks = remaining
+	if err := trash.Save(); err != nil {
+		return fmt.Errorf("failed to save trash: %v", err)
+	}
+
+	fmt.Println("Run 'gtasks2md export' or 'gtasks2md sync' to bring the restored tasks into your Markdown files.")
+	return nil
+}
+
+// waitingFor reports whether the task with the given ID is still waiting to be restored.
+func waitingFor(pending []*TrashedTask, id string) bool {
+	for _, tt := range pending {
+		if tt.ID == id {
+			return true
+		}
+	}
+	return false
+}